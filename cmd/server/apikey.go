@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"go-news/pkg/auth"
+	"go-news/pkg/config"
+	"go-news/pkg/storage"
+)
+
+// runAPIKey выполняет команду apikey create NAME SCOPE...: создаёт ключ API
+// и выводит его в stdout. Так выпускается первый ключ администратора, когда
+// JWT не настроен и POST /api-keys вызвать нечем.
+func runAPIKey(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) < 3 || args[0] != "create" {
+		return errors.New("usage: apikey create NAME SCOPE...")
+	}
+	name, scopes := strings.TrimSpace(args[1]), args[2:]
+	for _, scope := range scopes {
+		switch scope {
+		case storage.ScopeRead, storage.ScopeWrite, storage.ScopeAdmin:
+		default:
+			return fmt.Errorf("unknown scope %q: must be %s, %s or %s", scope, storage.ScopeRead, storage.ScopeWrite, storage.ScopeAdmin)
+		}
+	}
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+	if name == "" {
+		return errors.New("API key name must not be empty")
+	}
+	if cfg.StorageDriver == config.DriverMemory {
+		return errors.New("memory storage does not keep API keys between runs")
+	}
+
+	db, err := openStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("open %s storage: %w", cfg.StorageDriver, err)
+	}
+	defer db.Close()
+	if m, ok := db.(storage.Migrator); ok && cfg.AutoMigrate {
+		if err := m.MigrateUp(ctx); err != nil {
+			return fmt.Errorf("migrate %s storage: %w", cfg.StorageDriver, err)
+		}
+	}
+
+	key, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return err
+	}
+	k, err := db.AddAPIKey(ctx, storage.APIKey{
+		Name:      name,
+		Scopes:    scopes,
+		Hash:      hash,
+		CreatedBy: "cli",
+		CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Created API key %d %q with scopes %s", k.ID, k.Name, strings.Join(k.Scopes, ","))
+	// Ключ выводится отдельной строкой: узнать его потом будет негде
+	fmt.Println(key)
+	return nil
+}
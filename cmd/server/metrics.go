@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// serveMetrics отдаёт метрики handler по адресу addr/metrics до отмены ctx.
+// Это отдельный сервер без аутентификации: адрес должен быть доступен
+// только Prometheus, а не клиентам API.
+func serveMetrics(ctx context.Context, addr string, handler http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", handler)
+	hs := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		hs.Close()
+	}()
+	log.Printf("Metrics available on %s/metrics", addr)
+	if err := hs.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Metrics server failed: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"go-news/pkg/config"
+	"go-news/pkg/storage"
+)
+
+// runMigrate выполняет команду migrate: up (по умолчанию), down [N] или status.
+func runMigrate(ctx context.Context, cfg *config.Config, args []string) error {
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	if (cmd != "up" && cmd != "down" && cmd != "status") || len(args) > 2 || (len(args) == 2 && cmd != "down") {
+		return errors.New("usage: migrate [up | down [N] | status]")
+	}
+	n := 1
+	if len(args) == 2 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+			return fmt.Errorf("migrate down: N must be a positive number, got %q", args[1])
+		}
+	}
+
+	db, err := openStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("open %s storage: %w", cfg.StorageDriver, err)
+	}
+	defer db.Close()
+	m, ok := db.(storage.Migrator)
+	if !ok {
+		return fmt.Errorf("%s storage has no schema to migrate", cfg.StorageDriver)
+	}
+
+	switch cmd {
+	case "up":
+		err = m.MigrateUp(ctx)
+	case "down":
+		err = m.MigrateDown(ctx, n)
+	}
+	if err != nil {
+		return err
+	}
+	version, err := m.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("Schema version: %d", version)
+	return nil
+}
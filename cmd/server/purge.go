@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go-news/pkg/storage"
+)
+
+// purgeActor - автор записей истории об очистке корзины.
+const purgeActor = "system:purge"
+
+// purgeTrash раз в interval окончательно удаляет задачи, удалённые раньше
+// чем retentionDays дней назад. Возвращается после отмены ctx.
+func purgeTrash(ctx context.Context, db storage.Interface, retentionDays int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		before := time.Now().AddDate(0, 0, -retentionDays).Unix()
+		n, err := db.PurgeTasks(storage.WithActor(ctx, purgeActor), before)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Printf("Failed to purge deleted tasks: %v", err)
+		case n > 0:
+			log.Printf("Purged %d deleted tasks", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,37 +1,244 @@
-package main
-
-import (
-	"log"
-	"net/http"
-
-	"go-news/pkg/api"
-	"go-news/pkg/storage"
-	"go-news/pkg/storage/postgres"
-)
-
-type server struct {
-	db  storage.Interface
-	api *api.API
-}
-
-func main() {
-	// Подключение к Postgres
-	connStr := "postgres://news_user:news_pass@db:5432/news"
-
-	db, err := postgres.New(connStr)
-	if err != nil {
-		log.Fatalf("Failed to connect to Postgres: %v", err)
-	}
-
-	srv := server{
-		db: db,
-	}
-
-	// Создаём API с подключением к БД
-	srv.api = api.New(srv.db)
-
-	log.Println("Server running on :8080")
-	if err := http.ListenAndServe(":8080", srv.api.Router()); err != nil {
-		log.Fatalf("Server failed: %v", err)
-	}
-}
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go-news/pkg/api"
+	"go-news/pkg/auth"
+	"go-news/pkg/certs"
+	"go-news/pkg/config"
+	"go-news/pkg/metrics"
+	"go-news/pkg/storage"
+	"go-news/pkg/storage/memdb"
+	"go-news/pkg/storage/mongo"
+	"go-news/pkg/storage/postgres"
+	"go-news/pkg/tracing"
+)
+
+type server struct {
+	db  storage.Interface
+	api *api.API
+}
+
+func main() {
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to YAML config file (env CONFIG_FILE)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [migrate [up | down [N] | status] | apikey create NAME SCOPE...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg, err := config.LoadFile(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Configuration validation failed: %v", err)
+	}
+	// Журнал в JSON; log.Printf тоже пишет через этот обработчик
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: cfg.LogLevel()}))
+	slog.SetDefault(logger)
+	log.Printf("Loaded config: %s", cfg.LogMask())
+
+	// SIGTERM присылают docker stop и оркестраторы, SIGINT - Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch flag.Arg(0) {
+	case "":
+	case "migrate":
+		if err := runMigrate(ctx, cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	case "apikey":
+		if err := runAPIKey(ctx, cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("API key command failed: %v", err)
+		}
+		return
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, cfg.OTLPEndpoint)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	db, err := openStorage(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open %s storage: %v", cfg.StorageDriver, err)
+	}
+	if m, ok := db.(storage.Migrator); ok && cfg.AutoMigrate {
+		if err := m.MigrateUp(ctx); err != nil {
+			log.Fatalf("Failed to migrate %s storage: %v", cfg.StorageDriver, err)
+		}
+	}
+
+	m := metrics.New()
+	if pool, ok := db.(metrics.PoolStater); ok {
+		m.MustRegister(metrics.NewPoolCollector(pool))
+	}
+
+	srv := server{
+		db: m.Storage(tracing.Storage(db)),
+	}
+
+	opts := []api.Option{
+		api.WithRequestTimeout(cfg.RequestTimeout),
+		api.WithMetrics(m),
+		api.WithLogger(logger),
+	}
+	switch {
+	case cfg.JWTEnabled():
+		verifier, err := newVerifier(cfg)
+		if err != nil {
+			log.Fatalf("Failed to set up authentication: %v", err)
+		}
+		opts = append(opts, api.WithAuth(verifier))
+	case cfg.APIKeysEnabled:
+		log.Printf("JWT authentication disabled: only API keys are accepted")
+		opts = append(opts, api.WithAPIKeys())
+	default:
+		log.Printf("Authentication disabled: none of JWT_SECRET, JWKS_FILE or API_KEYS_ENABLED is set")
+	}
+	// Лимиты и прокси уже проверены при загрузке конфигурации.
+	limits, _ := cfg.Limits()
+	proxies, _ := cfg.Proxies()
+	opts = append(opts, api.WithRateLimits(limits), api.WithTrustedProxies(proxies))
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		opts = append(opts, api.WithCORS(api.CORS{
+			AllowedOrigins:   cfg.CORSAllowedOrigins,
+			AllowedMethods:   cfg.CORSAllowedMethods,
+			AllowedHeaders:   cfg.CORSAllowedHeaders,
+			AllowCredentials: cfg.CORSAllowCredentials,
+			MaxAge:           cfg.CORSMaxAge,
+		}))
+	}
+
+	// Создаём API с подключением к БД
+	srv.api = api.New(srv.db, opts...)
+	if err := srv.api.CheckRateLimits(); err != nil {
+		log.Fatalf("Invalid RATE_LIMITS: %v", err)
+	}
+
+	httpServer := &http.Server{
+		Addr:              ":" + cfg.AppPort,
+		Handler:           srv.api.Router(),
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+
+	if cfg.TLSEnabled() {
+		reloader, err := certs.NewReloader(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificates: %v", err)
+		}
+		httpServer.TLSConfig = reloader.TLSConfig(cfg.TLSClientAuth)
+		go reloadCerts(ctx, reloader, cfg.TLSReloadInterval)
+	}
+
+	if cfg.MetricsAddr != "" {
+		go serveMetrics(ctx, cfg.MetricsAddr, m.Handler())
+	}
+
+	if cfg.TrashRetentionDays > 0 {
+		go purgeTrash(ctx, srv.db, cfg.TrashRetentionDays, cfg.PurgeInterval)
+	}
+
+	if httpServer.TLSConfig != nil {
+		log.Printf("Server running on %s (HTTPS)", httpServer.Addr)
+	} else {
+		log.Printf("Server running on %s", httpServer.Addr)
+	}
+	err = serve(ctx, httpServer, cfg.ShutdownTimeout)
+	if closeErr := srv.db.Close(); closeErr != nil {
+		log.Printf("Failed to close storage: %v", closeErr)
+	}
+	// Отправляем накопленные span до выхода
+	flushCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	if traceErr := shutdownTracing(flushCtx); traceErr != nil {
+		log.Printf("Failed to flush traces: %v", traceErr)
+	}
+	cancel()
+	if err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+	log.Println("Server stopped")
+}
+
+// serve обслуживает запросы (по HTTPS, если задан hs.TLSConfig) до отмены
+// ctx, после чего перестаёт принимать новые соединения и ждёт завершения
+// текущих запросов, но не дольше timeout.
+func serve(ctx context.Context, hs *http.Server, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		if hs.TLSConfig != nil {
+			// Сертификаты берутся из TLSConfig.GetCertificate
+			errc <- hs.ListenAndServeTLS("", "")
+			return
+		}
+		errc <- hs.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down, waiting for in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := hs.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown: %w", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newVerifier создаёт проверку JWT с ключами из cfg.
+func newVerifier(cfg *config.Config) (*auth.Verifier, error) {
+	var opts []auth.Option
+	if cfg.JWTSecret != "" {
+		opts = append(opts, auth.WithSecret([]byte(cfg.JWTSecret)))
+	}
+	if cfg.JWKSFile != "" {
+		opts = append(opts, auth.WithJWKSFile(cfg.JWKSFile))
+	}
+	if cfg.JWTIssuer != "" {
+		opts = append(opts, auth.WithIssuer(cfg.JWTIssuer))
+	}
+	if cfg.JWTAudience != "" {
+		opts = append(opts, auth.WithAudience(cfg.JWTAudience))
+	}
+	return auth.New(opts...)
+}
+
+// openStorage создаёт хранилище, выбранное в cfg.StorageDriver.
+func openStorage(cfg *config.Config) (storage.Interface, error) {
+	switch cfg.StorageDriver {
+	case config.DriverPostgres:
+		return postgres.New(cfg.GetDSN())
+	case config.DriverMongo:
+		return mongo.New(cfg.MongoURI)
+	case config.DriverMemory:
+		return memdb.New(), nil
+	}
+	return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go-news/pkg/certs"
+)
+
+// reloadCerts раз в interval перечитывает сертификаты, если их файлы
+// изменились. Возвращается после отмены ctx.
+func reloadCerts(ctx context.Context, r *certs.Reloader, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reloaded, err := r.Reload()
+		switch {
+		case err != nil:
+			log.Printf("Failed to reload TLS certificates, keeping the current ones: %v", err)
+		case reloaded:
+			log.Printf("Reloaded TLS certificates")
+		}
+	}
+}
@@ -1,83 +1,122 @@
-package main
-
-import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"go-news/pkg/storage"
-	"io"
-	"net/http"
-)
-
-const (
-	greenColor = "\033[32m"
-	redColor   = "\033[31m"
-	resetColor = "\033[0m"
-)
-
-func printResult(operation string, err error) {
-	if err != nil {
-		fmt.Printf("%s%s: ERROR - %v%s\n", redColor, operation, err, resetColor)
-		return
-	}
-	fmt.Printf("%s%s: SUCCESS%s\n", greenColor, operation, resetColor)
-}
-
-func main() {
-	baseURL := "http://localhost:8080/posts"
-
-	// GET - получение всех задач
-	resp, err := http.Get(baseURL)
-	if err != nil {
-		printResult("GET", err)
-		return
-	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("Current tasks: %s\n", string(body))
-	printResult("GET", nil)
-
-	// POST - создание новой задачи
-	task := storage.Task{
-		ID:              100,
-		ResponsibleID:   1,
-		ResponsibleName: "Test User",
-		Context:         "Test Task",
-		AssignedAt:      1673891100,
-		DueDate:         1674064800,
-	}
-
-	jsonData, _ := json.Marshal(task)
-	resp, err = http.Post(baseURL, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		printResult("POST", err)
-		return
-	}
-	resp.Body.Close()
-	printResult("POST", nil)
-
-	// PUT - обновление задачи
-	task.Context = "Updated Test Task"
-	jsonData, _ = json.Marshal(task)
-	req, _ := http.NewRequest(http.MethodPut, baseURL, bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
-	resp, err = http.DefaultClient.Do(req)
-	if err != nil {
-		printResult("PUT", err)
-		return
-	}
-	resp.Body.Close()
-	printResult("PUT", nil)
-
-	// DELETE - удаление задачи
-	jsonData, _ = json.Marshal(task)
-	req, _ = http.NewRequest(http.MethodDelete, baseURL, bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
-	resp, err = http.DefaultClient.Do(req)
-	if err != nil {
-		printResult("DELETE", err)
-		return
-	}
-	resp.Body.Close()
-	printResult("DELETE", nil)
-}
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-news/pkg/storage"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+const (
+	greenColor = "\033[32m"
+	redColor   = "\033[31m"
+	resetColor = "\033[0m"
+)
+
+func printResult(operation string, err error) {
+	if err != nil {
+		fmt.Printf("%s%s: ERROR - %v%s\n", redColor, operation, err, resetColor)
+		return
+	}
+	fmt.Printf("%s%s: SUCCESS%s\n", greenColor, operation, resetColor)
+}
+
+// authTransport добавляет в каждый запрос заголовок аутентификации.
+type authTransport struct {
+	header, value string
+}
+
+func (t authTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set(t.header, t.value)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func main() {
+	baseURL := "http://localhost:8080/posts"
+
+	// Если сервер требует аутентификацию, ключ API передаётся в переменной
+	// API_KEY (нужна область admin: тест удаляет задачу) или JWT - в API_TOKEN
+	if key := os.Getenv("API_KEY"); key != "" {
+		http.DefaultClient.Transport = authTransport{header: "X-API-Key", value: key}
+	} else if token := os.Getenv("API_TOKEN"); token != "" {
+		http.DefaultClient.Transport = authTransport{header: "Authorization", value: "Bearer " + token}
+	}
+
+	// GET - получение всех задач
+	resp, err := http.Get(baseURL)
+	if err != nil {
+		printResult("GET", err)
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	fmt.Printf("Current tasks: %s\n", string(body))
+	printResult("GET", nil)
+
+	// POST - создание новой задачи
+	task := storage.Task{
+		ResponsibleID:   1,
+		ResponsibleName: "Test User",
+		Context:         "Test Task",
+		AssignedAt:      1673891100,
+		DueDate:         1674064800,
+	}
+
+	jsonData, _ := json.Marshal(task)
+	resp, err = http.Post(baseURL, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		printResult("POST", err)
+		return
+	}
+	// ID задачи назначается сервером и возвращается в теле ответа
+	err = json.NewDecoder(resp.Body).Decode(&task)
+	resp.Body.Close()
+	if err != nil {
+		printResult("POST", err)
+		return
+	}
+	printResult("POST", nil)
+
+	taskURL := baseURL + "/" + strconv.Itoa(task.ID)
+
+	// GET - получение созданной задачи; ETag - её текущая версия
+	resp, err = http.Get(taskURL)
+	if err != nil {
+		printResult("GET by ID", err)
+		return
+	}
+	resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	printResult("GET by ID", nil)
+
+	// PUT - обновление задачи
+	task.Context = "Updated Test Task"
+	jsonData, _ = json.Marshal(task)
+	req, _ := http.NewRequest(http.MethodPut, taskURL, bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		printResult("PUT", err)
+		return
+	}
+	resp.Body.Close()
+	etag = resp.Header.Get("ETag")
+	printResult("PUT", nil)
+
+	// DELETE - удаление задачи
+	req, _ = http.NewRequest(http.MethodDelete, taskURL, nil)
+	req.Header.Set("If-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		printResult("DELETE", err)
+		return
+	}
+	resp.Body.Close()
+	printResult("DELETE", nil)
+}
@@ -1,98 +1,515 @@
-package api
-
-import (
- "encoding/json"
- "go-news/pkg/storage"
- "net/http"
-
- "github.com/gorilla/mux"
-)
-
-type API struct {
- db     storage.Interface
- router *mux.Router
-}
-
-func New(db storage.Interface) *API {
- api := API{
-  db: db,
- }
- api.router = mux.NewRouter()
- api.endpoints()
- return &api
-}
-
-func (api *API) endpoints() {
- api.router.HandleFunc("/posts", api.postsHandler).Methods(http.MethodGet, http.MethodOptions)
- api.router.HandleFunc("/posts", api.addPostHandler).Methods(http.MethodPost, http.MethodOptions)
- api.router.HandleFunc("/posts", api.updatePostHandler).Methods(http.MethodPut, http.MethodOptions)
- api.router.HandleFunc("/posts", api.deletePostHandler).Methods(http.MethodDelete, http.MethodOptions)
-}
-
-func (api *API) Router() *mux.Router {
- return api.router
-}
-
-func (api *API) postsHandler(w http.ResponseWriter, r *http.Request) {
- posts, err := api.db.Tasks()
- if err != nil {
-  http.Error(w, err.Error(), http.StatusInternalServerError)
-  return
- }
- bytes, err := json.Marshal(posts)
- if err != nil {
-  http.Error(w, err.Error(), http.StatusInternalServerError)
-  return
- }
- _, err = w.Write(bytes)
- if err != nil {
-  http.Error(w, err.Error(), http.StatusInternalServerError)
-  return
- }
-}
-
-func (api *API) addPostHandler(w http.ResponseWriter, r *http.Request) {
- var p storage.Task
- err := json.NewDecoder(r.Body).Decode(&p)
- if err != nil {
-  http.Error(w, err.Error(), http.StatusInternalServerError)
-  return
- }
- err = api.db.AddTask(p)
- if err != nil {
-  http.Error(w, err.Error(), http.StatusInternalServerError)
-  return
- }
- w.WriteHeader(http.StatusOK)
-}
-
-func (api *API) updatePostHandler(w http.ResponseWriter, r *http.Request) {
- var p storage.Task
- err := json.NewDecoder(r.Body).Decode(&p)
- if err != nil {
-  http.Error(w, err.Error(), http.StatusInternalServerError)
-  return
- }
- err = api.db.UpdateTask(p)
- if err != nil {
-  http.Error(w, err.Error(), http.StatusInternalServerError)
-  return
- }
- w.WriteHeader(http.StatusOK)
-}
-
-func (api *API) deletePostHandler(w http.ResponseWriter, r *http.Request) {
- var p storage.Task
- err := json.NewDecoder(r.Body).Decode(&p)
- if err != nil {
-  http.Error(w, err.Error(), http.StatusInternalServerError)
-  return
- }
- err = api.db.DeleteTask(p)
- if err != nil {
-  http.Error(w, err.Error(), http.StatusInternalServerError)
-  return
- }
- w.WriteHeader(http.StatusOK)
-}
-
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-news/pkg/auth"
+	"go-news/pkg/metrics"
+	"go-news/pkg/ratelimit"
+	"go-news/pkg/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxBodySize - максимальный размер тела запроса в байтах.
+	maxBodySize = 1 << 20
+	// defaultSearchLimit - число результатов поиска по умолчанию.
+	defaultSearchLimit = 20
+)
+
+type API struct {
+	db      storage.Interface
+	router  *mux.Router
+	handler http.Handler
+	logger  *slog.Logger
+
+	requestTimeout time.Duration
+	metrics        *metrics.Metrics
+	auth           *auth.Verifier
+	authRequired   bool
+	limiters       map[string]*ratelimit.Limiter
+	limitRoutes    []string
+	trustedProxies []netip.Prefix
+	cors           *CORS
+}
+
+func New(db storage.Interface, opts ...Option) *API {
+	api := API{
+		db:     db,
+		logger: slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(&api)
+	}
+	api.router = mux.NewRouter()
+	api.router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	api.router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
+	if api.metrics != nil {
+		// Middleware роутера не вызываются для неизвестных путей и методов,
+		// поэтому обработчики этих ошибок оборачиваются отдельно.
+		api.router.NotFoundHandler = api.metrics.Middleware(api.router.NotFoundHandler)
+		api.router.MethodNotAllowedHandler = api.metrics.Middleware(api.router.MethodNotAllowedHandler)
+		api.router.Use(api.metrics.Middleware)
+	}
+	api.router.Use(spanRouteMiddleware)
+	if api.requestTimeout > 0 {
+		api.router.Use(api.timeoutMiddleware)
+	}
+	if api.authRequired {
+		api.router.Use(api.authMiddleware)
+	}
+	if len(api.limiters) > 0 {
+		// После аутентификации: клиенты с ключом или токеном
+		// ограничиваются по subject, а не по IP.
+		api.router.Use(api.rateLimitMiddleware)
+	}
+	api.endpoints()
+	api.handler = api.router
+	if api.cors != nil {
+		// Вне маршрутизатора: заголовки CORS нужны и ответам 404 и 405.
+		api.handler = api.corsMiddleware(api.handler)
+	}
+	api.handler = api.requestIDMiddleware(api.tracingMiddleware(api.loggingMiddleware(api.handler)))
+	return &api
+}
+
+func (api *API) endpoints() {
+	api.router.HandleFunc("/healthz", api.healthzHandler).Methods(http.MethodGet, http.MethodHead)
+	api.router.HandleFunc("/readyz", api.readyzHandler).Methods(http.MethodGet, http.MethodHead)
+	api.router.HandleFunc("/posts", api.postsHandler).Methods(http.MethodGet)
+	api.router.HandleFunc("/posts", api.addPostHandler).Methods(http.MethodPost)
+	api.router.HandleFunc("/posts/search", api.searchHandler).Methods(http.MethodGet)
+	api.router.HandleFunc("/posts/trash", api.trashHandler).Methods(http.MethodGet)
+	api.router.HandleFunc("/posts/{id:[0-9]+}", api.postHandler).Methods(http.MethodGet)
+	api.router.HandleFunc("/posts/{id:[0-9]+}", api.updatePostHandler).Methods(http.MethodPut)
+	api.router.HandleFunc("/posts/{id:[0-9]+}", api.patchPostHandler).Methods(http.MethodPatch)
+	api.router.HandleFunc("/posts/{id:[0-9]+}", api.deletePostHandler).Methods(http.MethodDelete)
+	api.router.HandleFunc("/posts/{id:[0-9]+}/restore", api.restorePostHandler).Methods(http.MethodPost)
+	api.router.HandleFunc("/posts/{id:[0-9]+}/history", api.historyHandler).Methods(http.MethodGet)
+	if api.authRequired {
+		api.router.HandleFunc("/api-keys", api.apiKeysHandler).Methods(http.MethodGet)
+		api.router.HandleFunc("/api-keys", api.addAPIKeyHandler).Methods(http.MethodPost)
+		api.router.HandleFunc("/api-keys/{id:[0-9]+}", api.revokeAPIKeyHandler).Methods(http.MethodDelete)
+	}
+}
+
+// Router возвращает обработчик запросов API: маршрутизатор, обёрнутый
+// в middleware CORS, ID запроса, трассировки и журналирования.
+func (api *API) Router() http.Handler {
+	return api.handler
+}
+
+// postsHandler возвращает страницу задач. Общее число задач и курсор
+// следующей страницы передаются в заголовках X-Total-Count и X-Next-Cursor.
+func (api *API) postsHandler(w http.ResponseWriter, r *http.Request) {
+	api.writeTasks(w, r, false)
+}
+
+// trashHandler возвращает страницу удалённых задач с теми же параметрами
+// и заголовками, что и GET /posts. Корзина доступна только администраторам.
+func (api *API) trashHandler(w http.ResponseWriter, r *http.Request) {
+	if err := api.requireRole(r, auth.RoleAdmin); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	api.writeTasks(w, r, true)
+}
+
+// writeTasks отвечает страницей задач из корзины или вне её.
+func (api *API) writeTasks(w http.ResponseWriter, r *http.Request, deleted bool) {
+	q, err := parseTaskQuery(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	q.Deleted = deleted
+	page, err := api.db.Tasks(r.Context(), q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	if page.Tasks == nil {
+		page.Tasks = []storage.Task{}
+	}
+	writeJSON(w, r, http.StatusOK, page.Tasks)
+}
+
+// searchHandler ищет задачи по тексту: GET /posts/search?q=...&limit=...
+func (api *API) searchHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(storage.SearchTerms(query)) == 0 {
+		writeError(w, r, badRequest("query parameter q must contain at least one word"))
+		return
+	}
+	limit := defaultSearchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > storage.MaxLimit {
+			writeError(w, r, badRequest(fmt.Sprintf("query parameter limit must be between 1 and %d", storage.MaxLimit)))
+			return
+		}
+		limit = n
+	}
+	results, err := api.db.SearchTasks(r.Context(), query, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if results == nil {
+		results = []storage.SearchResult{}
+	}
+	writeJSON(w, r, http.StatusOK, results)
+}
+
+func (api *API) postHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	p, err := api.db.Task(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(p.Version))
+	writeJSON(w, r, http.StatusOK, p)
+}
+
+// addPostHandler создаёт задачу. ID назначается хранилищем, переданный
+// клиентом ID игнорируется.
+func (api *API) addPostHandler(w http.ResponseWriter, r *http.Request) {
+	var p storage.Task
+	err := decodeTask(w, r, &p)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	p.ID = 0
+	if caller, ok := api.caller(r); ok {
+		if err := checkCreate(caller, p); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	p, err = api.db.AddTask(r.Context(), p)
+	if err = ignoreHistoryError(r, err); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", "/posts/"+strconv.Itoa(p.ID))
+	w.Header().Set("ETag", etag(p.Version))
+	writeJSON(w, r, http.StatusCreated, p)
+}
+
+// updatePostHandler полностью заменяет задачу с ID из пути. Версия
+// задачи берётся из заголовка If-Match. Права исполнителя проверяются по
+// прочитанной задаче, и обновляется именно её версия.
+func (api *API) updatePostHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var p storage.Task
+	err = decodeTask(w, r, &p)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if caller, ok := api.caller(r); ok && !caller.HasRole(auth.RoleAdmin, auth.RoleManager) {
+		current, err := api.db.Task(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if err := checkUpdate(caller, current, p); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if version == 0 {
+			version = current.Version
+		}
+	}
+	p.ID, p.Version = id, version
+	p, err = api.db.UpdateTask(r.Context(), p)
+	if err = ignoreHistoryError(r, err); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(p.Version))
+	writeJSON(w, r, http.StatusOK, p)
+}
+
+// patchPostHandler обновляет только переданные в теле запроса поля задачи.
+// Изменения накладываются на прочитанную версию задачи, поэтому обновление
+// отклоняется, если задачу успели изменить, даже при If-Match: *.
+func (api *API) patchPostHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	p, err := api.db.Task(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if version != 0 && p.Version != version {
+		writeError(w, r, storage.ErrVersionMismatch)
+		return
+	}
+	version = p.Version
+	current := p
+	err = decodeTask(w, r, &p)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if caller, ok := api.caller(r); ok {
+		if err := checkUpdate(caller, current, p); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	p.ID, p.Version = id, version
+	p, err = api.db.UpdateTask(r.Context(), p)
+	if err = ignoreHistoryError(r, err); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(p.Version))
+	writeJSON(w, r, http.StatusOK, p)
+}
+
+// deletePostHandler удаляет задачу в корзину. Удалять задачи могут только
+// администраторы.
+func (api *API) deletePostHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := api.requireRole(r, auth.RoleAdmin); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	err = api.db.DeleteTask(r.Context(), storage.Task{ID: id, Version: version})
+	if err = ignoreHistoryError(r, err); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// restorePostHandler возвращает удалённую задачу из корзины. Как и при
+// удалении, If-Match должен содержать ETag задачи (версию из корзины), а
+// вызывающий - быть администратором.
+func (api *API) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := api.requireRole(r, auth.RoleAdmin); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	p, err := api.db.RestoreTask(r.Context(), storage.Task{ID: id, Version: version})
+	if err = ignoreHistoryError(r, err); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(p.Version))
+	writeJSON(w, r, http.StatusOK, p)
+}
+
+// historyHandler возвращает историю изменений задачи, в том числе удалённой.
+func (api *API) historyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	entries, err := api.db.TaskHistory(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if entries == nil {
+		entries = []storage.HistoryEntry{}
+	}
+	writeJSON(w, r, http.StatusOK, entries)
+}
+
+// decodeTask читает задачу из тела запроса поверх значений в p и проверяет её.
+func decodeTask(w http.ResponseWriter, r *http.Request, p *storage.Task) error {
+	if err := decodeJSON(w, r, p); err != nil {
+		return err
+	}
+	return p.Validate()
+}
+
+// decodeJSON читает JSON-объект из тела запроса в v. Неизвестные поля и
+// тело больше maxBodySize отвергаются.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil && dec.More() {
+		err = errors.New("unexpected data after JSON object")
+	}
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return newError(http.StatusRequestEntityTooLarge, codePayloadTooLarge,
+			fmt.Sprintf("request body must not exceed %d bytes", tooLarge.Limit))
+	case errors.Is(err, io.EOF):
+		return badRequest("request body must not be empty")
+	case err != nil:
+		return badRequest("invalid JSON body: " + err.Error())
+	}
+	return nil
+}
+
+// parseTaskQuery разбирает параметры выборки задач из строки запроса:
+// limit, cursor, responsible_id, due_from, due_to, assigned_from,
+// assigned_to и sort (поле сортировки, "-" перед ним - по убыванию).
+func parseTaskQuery(r *http.Request) (storage.TaskQuery, error) {
+	values := r.URL.Query()
+	var fields []FieldError
+	intParam := func(name string) *int64 {
+		v := values.Get(name)
+		if v == "" {
+			return nil
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			fields = append(fields, FieldError{Field: name, Message: "must be an integer"})
+			return nil
+		}
+		return &n
+	}
+
+	q := storage.TaskQuery{
+		DueFrom:      intParam("due_from"),
+		DueTo:        intParam("due_to"),
+		AssignedFrom: intParam("assigned_from"),
+		AssignedTo:   intParam("assigned_to"),
+		Cursor:       values.Get("cursor"),
+	}
+	if id := intParam("responsible_id"); id != nil {
+		q.ResponsibleID = new(int)
+		*q.ResponsibleID = int(*id)
+	}
+	if limit := intParam("limit"); limit != nil {
+		if *limit < 1 || *limit > storage.MaxLimit {
+			fields = append(fields, FieldError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", storage.MaxLimit)})
+		} else {
+			q.Limit = int(*limit)
+		}
+	}
+	q.Sort = values.Get("sort")
+	if strings.HasPrefix(q.Sort, "-") {
+		q.Sort, q.Desc = q.Sort[1:], true
+	}
+
+	if _, err := q.Normalize(); err != nil {
+		var vErr *storage.ValidationError
+		if !errors.As(err, &vErr) {
+			return q, err
+		}
+		for _, f := range vErr.Fields {
+			fields = append(fields, FieldError{Field: f.Field, Message: f.Message})
+		}
+	}
+	if len(fields) > 0 {
+		e := badRequest("invalid query parameters")
+		e.Fields = fields
+		return q, e
+	}
+	return q, nil
+}
+
+// taskID извлекает ID задачи из пути запроса.
+func taskID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, badRequest("invalid task id")
+	}
+	return id, nil
+}
+
+// etag возвращает значение заголовка ETag для версии задачи.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatch возвращает версию задачи из заголовка If-Match: "N" - версия N,
+// "*" - любая версия (0). Без заголовка изменение задачи запрещено (428),
+// значение, не совпадающее ни с одним ETag задачи, - ошибка 412.
+func ifMatch(r *http.Request) (int, error) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" {
+		return 0, newError(http.StatusPreconditionRequired, codePreconditionRequired,
+			"If-Match header with the task ETag is required")
+	}
+	if v == "*" {
+		return 0, nil
+	}
+	// Слабые ETag (W/"N") и списки значений не подходят: If-Match
+	// сравнивает ETag строго, а задача имеет одну версию.
+	if len(v) > 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		if n, err := strconv.Atoi(v[1 : len(v)-1]); err == nil && n > 0 {
+			return n, nil
+		}
+	}
+	return 0, storage.ErrVersionMismatch
+}
+
+// writeJSON сериализует v в тело ответа на запрос r с указанным статусом.
+// Ошибка сериализации записывается в журнал запроса.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		loggerFrom(r.Context()).Error("encode response", "method", r.Method, "path", r.URL.Path, "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(bytes)
+}
@@ -2,11 +2,46 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"go-news/pkg/auth"
+	"go-news/pkg/certs"
+	"go-news/pkg/metrics"
+	"go-news/pkg/ratelimit"
 	"go-news/pkg/storage"
+	"go-news/pkg/storage/memdb"
+	"go-news/pkg/tracing"
+	"io"
+	"log"
+	"log/slog"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // MockDB - mock реализация хранилища для тестирования
@@ -14,35 +49,144 @@ type MockDB struct {
 	tasks []storage.Task
 }
 
-func (m *MockDB) Tasks() ([]storage.Task, error) {
-	return m.tasks, nil
+func (m *MockDB) Tasks(context.Context, storage.TaskQuery) (storage.TaskPage, error) {
+	return storage.TaskPage{Tasks: m.tasks, Total: len(m.tasks)}, nil
+}
+
+func (m *MockDB) Task(_ context.Context, id int) (storage.Task, error) {
+	for _, t := range m.tasks {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+	return storage.Task{}, storage.ErrNotFound
+}
+
+func (m *MockDB) SearchTasks(context.Context, string, int) ([]storage.SearchResult, error) {
+	return nil, nil
 }
 
-func (m *MockDB) AddTask(task storage.Task) error {
+func (m *MockDB) AddTask(_ context.Context, task storage.Task) (storage.Task, error) {
+	task.ID = len(m.tasks) + 1
 	m.tasks = append(m.tasks, task)
-	return nil
+	return task, nil
 }
 
-func (m *MockDB) UpdateTask(task storage.Task) error {
+func (m *MockDB) UpdateTask(_ context.Context, task storage.Task) (storage.Task, error) {
 	for i, t := range m.tasks {
 		if t.ID == task.ID {
+			if task.Version != 0 && task.Version != t.Version {
+				return storage.Task{}, storage.ErrVersionMismatch
+			}
+			task.Version = t.Version + 1
 			m.tasks[i] = task
-			return nil
+			return task, nil
 		}
 	}
-	return nil
+	return storage.Task{}, storage.ErrNotFound
 }
 
-func (m *MockDB) DeleteTask(task storage.Task) error {
+func (m *MockDB) DeleteTask(_ context.Context, task storage.Task) error {
 	for i, t := range m.tasks {
 		if t.ID == task.ID {
+			if task.Version != 0 && task.Version != t.Version {
+				return storage.ErrVersionMismatch
+			}
 			m.tasks = append(m.tasks[:i], m.tasks[i+1:]...)
 			return nil
 		}
 	}
+	return storage.ErrNotFound
+}
+
+func (m *MockDB) RestoreTask(context.Context, storage.Task) (storage.Task, error) {
+	return storage.Task{}, storage.ErrNotFound
+}
+
+func (m *MockDB) PurgeTasks(context.Context, int64) (int, error) {
+	return 0, nil
+}
+
+func (m *MockDB) TaskHistory(context.Context, int) ([]storage.HistoryEntry, error) {
+	return nil, nil
+}
+
+func (m *MockDB) AddAPIKey(_ context.Context, k storage.APIKey) (storage.APIKey, error) {
+	return k, nil
+}
+
+func (m *MockDB) APIKeys(context.Context) ([]storage.APIKey, error) {
+	return nil, nil
+}
+
+func (m *MockDB) APIKeyByHash(context.Context, string) (storage.APIKey, error) {
+	return storage.APIKey{}, storage.ErrNotFound
+}
+
+func (m *MockDB) RevokeAPIKey(context.Context, int) error {
+	return storage.ErrNotFound
+}
+
+func (m *MockDB) TouchAPIKey(context.Context, int, int64) error {
 	return nil
 }
 
+func (m *MockDB) Close() error {
+	return nil
+}
+
+// FailingDB - хранилище, все методы которого возвращают заданную ошибку
+type FailingDB struct {
+	err error
+}
+
+func (f *FailingDB) Tasks(context.Context, storage.TaskQuery) (storage.TaskPage, error) {
+	return storage.TaskPage{}, f.err
+}
+func (f *FailingDB) Task(context.Context, int) (storage.Task, error) { return storage.Task{}, f.err }
+func (f *FailingDB) SearchTasks(context.Context, string, int) ([]storage.SearchResult, error) {
+	return nil, f.err
+}
+func (f *FailingDB) AddTask(context.Context, storage.Task) (storage.Task, error) {
+	return storage.Task{}, f.err
+}
+func (f *FailingDB) UpdateTask(context.Context, storage.Task) (storage.Task, error) {
+	return storage.Task{}, f.err
+}
+func (f *FailingDB) DeleteTask(context.Context, storage.Task) error { return f.err }
+func (f *FailingDB) RestoreTask(context.Context, storage.Task) (storage.Task, error) {
+	return storage.Task{}, f.err
+}
+func (f *FailingDB) PurgeTasks(context.Context, int64) (int, error) { return 0, f.err }
+func (f *FailingDB) TaskHistory(context.Context, int) ([]storage.HistoryEntry, error) {
+	return nil, f.err
+}
+func (f *FailingDB) AddAPIKey(context.Context, storage.APIKey) (storage.APIKey, error) {
+	return storage.APIKey{}, f.err
+}
+func (f *FailingDB) APIKeys(context.Context) ([]storage.APIKey, error) { return nil, f.err }
+func (f *FailingDB) APIKeyByHash(context.Context, string) (storage.APIKey, error) {
+	return storage.APIKey{}, f.err
+}
+func (f *FailingDB) RevokeAPIKey(context.Context, int) error       { return f.err }
+func (f *FailingDB) TouchAPIKey(context.Context, int, int64) error { return f.err }
+func (f *FailingDB) Close() error                                  { return nil }
+
+// HistoryFailingDB применяет изменения задач, но не может записать их в
+// историю, как MongoDB при сбое после изменения.
+type HistoryFailingDB struct {
+	storage.Interface
+}
+
+func (h *HistoryFailingDB) AddTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	t, _ = h.Interface.AddTask(ctx, t)
+	return t, fmt.Errorf("%w: connection reset", storage.ErrHistoryNotRecorded)
+}
+func (h *HistoryFailingDB) UpdateTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	t, _ = h.Interface.UpdateTask(ctx, t)
+	return t, fmt.Errorf("%w: connection reset", storage.ErrHistoryNotRecorded)
+}
+
 // Test 1: GET /posts - получение всех задач
 func TestGetPosts(t *testing.T) {
 	mockDB := &MockDB{
@@ -104,7 +248,7 @@ func TestAddPost(t *testing.T) {
 	api := New(mockDB)
 
 	newTask := storage.Task{
-		ID:              1,
+		ID:              100, // клиентский ID игнорируется
 		ResponsibleID:   1,
 		ResponsibleName: "Alice Johnson",
 		Context:         "New Task",
@@ -120,8 +264,20 @@ func TestAddPost(t *testing.T) {
 	api.Router().ServeHTTP(w, req)
 
 	// Проверяем статус код
-	if w.Code != http.StatusOK {
-		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	// Проверяем что ID назначен сервером
+	if loc := w.Header().Get("Location"); loc != "/posts/1" {
+		t.Errorf("Expected Location '/posts/1', got '%s'", loc)
+	}
+	var created storage.Task
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if created.ID != 1 {
+		t.Errorf("Expected server-assigned ID 1, got %d", created.ID)
 	}
 
 	// Проверяем что задача была добавлена в БД
@@ -135,7 +291,7 @@ func TestAddPost(t *testing.T) {
 	}
 }
 
-// Test 3: PUT /posts - обновление задачи
+// Test 3: PUT /posts/{id} - обновление задачи
 func TestUpdatePost(t *testing.T) {
 	mockDB := &MockDB{
 		tasks: []storage.Task{
@@ -162,8 +318,9 @@ func TestUpdatePost(t *testing.T) {
 	}
 
 	jsonData, _ := json.Marshal(updatedTask)
-	req := httptest.NewRequest(http.MethodPut, "/posts", bytes.NewBuffer(jsonData))
+	req := httptest.NewRequest(http.MethodPut, "/posts/1", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", "*")
 	w := httptest.NewRecorder()
 
 	api.Router().ServeHTTP(w, req)
@@ -188,7 +345,7 @@ func TestUpdatePost(t *testing.T) {
 	}
 }
 
-// Test 4: DELETE /posts - удаление задачи
+// Test 4: DELETE /posts/{id} - удаление задачи
 func TestDeletePost(t *testing.T) {
 	mockDB := &MockDB{
 		tasks: []storage.Task{
@@ -213,13 +370,8 @@ func TestDeletePost(t *testing.T) {
 
 	api := New(mockDB)
 
-	taskToDelete := storage.Task{
-		ID: 1,
-	}
-
-	jsonData, _ := json.Marshal(taskToDelete)
-	req := httptest.NewRequest(http.MethodDelete, "/posts", bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
+	req := httptest.NewRequest(http.MethodDelete, "/posts/1", nil)
+	req.Header.Set("If-Match", "*")
 	w := httptest.NewRecorder()
 
 	api.Router().ServeHTTP(w, req)
@@ -239,3 +391,1473 @@ func TestDeletePost(t *testing.T) {
 		t.Errorf("Expected remaining task with ID 2, got %d", mockDB.tasks[0].ID)
 	}
 }
+
+// Test 5: GET /posts/{id} - получение одной задачи
+func TestGetPost(t *testing.T) {
+	mockDB := &MockDB{
+		tasks: []storage.Task{
+			{ID: 1, ResponsibleID: 1, ResponsibleName: "John Doe", Context: "Task 1"},
+			{ID: 2, ResponsibleID: 2, ResponsibleName: "Jane Smith", Context: "Task 2"},
+		},
+	}
+	api := New(mockDB)
+
+	req := httptest.NewRequest(http.MethodGet, "/posts/2", nil)
+	w := httptest.NewRecorder()
+	api.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var post storage.Task
+	if err := json.NewDecoder(w.Body).Decode(&post); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if post.ID != 2 || post.ResponsibleName != "Jane Smith" {
+		t.Errorf("Post data mismatch: ID=%d, Name=%s", post.ID, post.ResponsibleName)
+	}
+}
+
+// Test 6: запросы к несуществующей задаче возвращают 404
+func TestPostNotFound(t *testing.T) {
+	mockDB := &MockDB{tasks: []storage.Task{{ID: 1, Context: "Task 1"}}}
+	api := New(mockDB)
+
+	tests := []struct {
+		method string
+		body   string
+	}{
+		{method: http.MethodGet},
+		{method: http.MethodPut, body: `{"context":"x"}`},
+		{method: http.MethodPatch, body: `{"context":"x"}`},
+		{method: http.MethodDelete},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/posts/42", bytes.NewBufferString(tt.body))
+			req.Header.Set("If-Match", "*")
+			w := httptest.NewRecorder()
+			api.Router().ServeHTTP(w, req)
+
+			if w.Code != http.StatusNotFound {
+				t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+			}
+		})
+	}
+}
+
+// Test 7: PATCH /posts/{id} - частичное обновление задачи
+func TestPatchPost(t *testing.T) {
+	mockDB := &MockDB{
+		tasks: []storage.Task{
+			{
+				ID:              1,
+				ResponsibleID:   1,
+				ResponsibleName: "John Doe",
+				Context:         "Old Task",
+				AssignedAt:      1673891100,
+				DueDate:         1674064800,
+			},
+		},
+	}
+	api := New(mockDB)
+
+	req := httptest.NewRequest(http.MethodPatch, "/posts/1", bytes.NewBufferString(`{"context":"Patched Task"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", "*")
+	w := httptest.NewRecorder()
+	api.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// Изменилось только переданное поле
+	if mockDB.tasks[0].Context != "Patched Task" {
+		t.Errorf("Expected context 'Patched Task', got '%s'", mockDB.tasks[0].Context)
+	}
+	if mockDB.tasks[0].ResponsibleName != "John Doe" || mockDB.tasks[0].DueDate != 1674064800 {
+		t.Errorf("PATCH overwrote fields that were not sent: %+v", mockDB.tasks[0])
+	}
+}
+
+// Test 8: ошибки хранилища отображаются в соответствующие HTTP-статусы
+func TestStorageErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"not found", storage.ErrNotFound, http.StatusNotFound},
+		{"wrapped not found", fmt.Errorf("%w: id 1", storage.ErrNotFound), http.StatusNotFound},
+		{"conflict", fmt.Errorf("%w: duplicate key", storage.ErrConflict), http.StatusConflict},
+		{"invalid", fmt.Errorf("%w: check violation", storage.ErrInvalid), http.StatusBadRequest},
+		{"version mismatch", storage.ErrVersionMismatch, http.StatusPreconditionFailed},
+		{"other", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := New(&FailingDB{err: tt.err})
+
+			req := httptest.NewRequest(http.MethodPut, "/posts/1", bytes.NewBufferString(`{"context":"x"}`))
+			req.Header.Set("If-Match", "*")
+			w := httptest.NewRecorder()
+			api.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected status code %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+
+	// Ошибки хранилища при работе с ключами API не описываются как ошибки задачи
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api-keys", nil)
+	writeResourceError(w, req, "API key", fmt.Errorf("%w: duplicate hash", storage.ErrConflict))
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), `"API key conflicts with existing data"`) {
+		t.Errorf("Expected API key conflict, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+// Test 9: ошибки возвращаются в едином JSON-формате без текста ошибок драйвера
+func TestErrorResponseFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		db       storage.Interface
+		method   string
+		target   string
+		body     string
+		wantCode int
+		wantErr  string
+	}{
+		{"not found", &MockDB{}, http.MethodGet, "/posts/1", "", http.StatusNotFound, "not_found"},
+		{"bad json", &MockDB{}, http.MethodPost, "/posts", "{", http.StatusBadRequest, "bad_request"},
+		{"unknown route", &MockDB{}, http.MethodGet, "/unknown", "", http.StatusNotFound, "not_found"},
+		{"bad method", &MockDB{}, http.MethodPost, "/posts/1", "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"internal", &FailingDB{err: errors.New("pq: password authentication failed")}, http.MethodGet, "/posts", "", http.StatusInternalServerError, "internal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := New(tt.db)
+
+			req := httptest.NewRequest(tt.method, tt.target, bytes.NewBufferString(tt.body))
+			req.Header.Set("X-Request-ID", "test-request")
+			w := httptest.NewRecorder()
+			api.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status code %d, got %d", tt.wantCode, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Expected Content-Type 'application/json', got '%s'", ct)
+			}
+
+			var resp ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode error response: %v", err)
+			}
+			if resp.Error.Code != tt.wantErr {
+				t.Errorf("Expected error code '%s', got '%s'", tt.wantErr, resp.Error.Code)
+			}
+			if resp.Error.RequestID != "test-request" {
+				t.Errorf("Expected request ID 'test-request', got '%s'", resp.Error.RequestID)
+			}
+			if contains(resp.Error.Message, "password") {
+				t.Errorf("Error message leaks storage error: %s", resp.Error.Message)
+			}
+		})
+	}
+}
+
+// Test 10: некорректные задачи отвергаются одинаково при создании и обновлении
+func TestTaskValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantFields []string
+	}{
+		{
+			name:       "empty context",
+			body:       `{"responsible_id":1,"context":"  "}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantFields: []string{"context"},
+		},
+		{
+			name:       "negative responsible and due before assigned",
+			body:       `{"responsible_id":-1,"context":"Task","assigned_at":200,"due_date":100}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantFields: []string{"responsible_id", "due_date"},
+		},
+		{
+			name:       "unknown field",
+			body:       `{"context":"Task","priority":1}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "trailing data",
+			body:       `{"context":"Task"}{"context":"Task"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "too large",
+			body:       `{"context":"` + strings.Repeat("a", maxBodySize) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch} {
+		for _, tt := range tests {
+			t.Run(method+" "+tt.name, func(t *testing.T) {
+				mockDB := &MockDB{tasks: []storage.Task{{ID: 1, Context: "Task 1"}}}
+				api := New(mockDB)
+
+				target := "/posts/1"
+				if method == http.MethodPost {
+					target = "/posts"
+				}
+				req := httptest.NewRequest(method, target, strings.NewReader(tt.body))
+				req.Header.Set("If-Match", "*")
+				w := httptest.NewRecorder()
+				api.Router().ServeHTTP(w, req)
+
+				if w.Code != tt.wantStatus {
+					t.Fatalf("Expected status code %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+				}
+
+				var resp ErrorResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("Failed to decode error response: %v", err)
+				}
+				var fields []string
+				for _, f := range resp.Error.Fields {
+					fields = append(fields, f.Field)
+				}
+				if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+					t.Errorf("Expected field errors %v, got %v", tt.wantFields, fields)
+				}
+
+				// Хранилище не изменилось
+				if len(mockDB.tasks) != 1 || mockDB.tasks[0].Context != "Task 1" {
+					t.Errorf("Invalid task reached storage: %+v", mockDB.tasks)
+				}
+			})
+		}
+	}
+}
+
+// Test 11: GET /posts - фильтры, сортировка и пагинация по курсору
+func TestPostsPagination(t *testing.T) {
+	db := memdb.New()
+	for i := 1; i <= 5; i++ {
+		_, _ = db.AddTask(context.Background(), storage.Task{
+			ResponsibleID: 100 + i%2,
+			Context:       fmt.Sprintf("Task %d", i),
+			AssignedAt:    int64(1000 + i),
+			DueDate:       int64(2000 - i),
+		})
+	}
+	api := New(db)
+
+	get := func(target string) ([]storage.Task, *httptest.ResponseRecorder) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		w := httptest.NewRecorder()
+		api.Router().ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected status code %d, got %d: %s", target, http.StatusOK, w.Code, w.Body.String())
+		}
+		var posts []storage.Task
+		if err := json.NewDecoder(w.Body).Decode(&posts); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return posts, w
+	}
+
+	// Обходим все задачи ответственного 101 страницами по 2, по убыванию срока
+	var ids []int
+	target := "/posts?responsible_id=101&sort=-due_date&limit=2"
+	for page := 0; target != ""; page++ {
+		if page > 3 {
+			t.Fatal("Pagination did not terminate")
+		}
+		posts, w := get(target)
+		if total := w.Header().Get("X-Total-Count"); total != "3" {
+			t.Errorf("Expected X-Total-Count 3, got %s", total)
+		}
+		for _, p := range posts {
+			ids = append(ids, p.ID)
+		}
+		target = ""
+		if next := w.Header().Get("X-Next-Cursor"); next != "" {
+			target = "/posts?responsible_id=101&sort=-due_date&limit=2&cursor=" + next
+		}
+	}
+	// Задачи 3, 5, 7 (ID 1 и 2 - тестовые задачи memdb) с убывающим сроком
+	if fmt.Sprint(ids) != "[3 5 7]" {
+		t.Errorf("Expected tasks [3 5 7], got %v", ids)
+	}
+
+	// Диапазон дат назначения
+	posts, _ := get("/posts?assigned_from=1002&assigned_to=1003")
+	if len(posts) != 2 || posts[0].ID != 4 || posts[1].ID != 5 {
+		t.Errorf("Expected tasks 4 and 5 in assigned range, got %+v", posts)
+	}
+}
+
+// Test 12: GET /posts - некорректные параметры выборки
+func TestPostsInvalidQuery(t *testing.T) {
+	api := New(memdb.New())
+
+	tests := []struct {
+		query string
+		field string
+	}{
+		{"limit=0", "limit"},
+		{"limit=100000", "limit"},
+		{"responsible_id=abc", "responsible_id"},
+		{"sort=context", "sort"},
+		{"cursor=not-a-cursor", "cursor"},
+		{"sort=-due_date&cursor=" + storage.TaskQuery{Sort: storage.SortID}.NextCursor(storage.Task{ID: 1}), "cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/posts?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			api.Router().ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+			}
+			var resp ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode error response: %v", err)
+			}
+			if len(resp.Error.Fields) != 1 || resp.Error.Fields[0].Field != tt.field {
+				t.Errorf("Expected error in field %s, got %+v", tt.field, resp.Error.Fields)
+			}
+		})
+	}
+}
+
+// Test 13: GET /posts/search - поиск по тексту задач
+func TestSearchPosts(t *testing.T) {
+	db := memdb.New()
+	_, _ = db.AddTask(context.Background(), storage.Task{Context: "Настроить <nginx> и проверить nginx.conf"})
+	_, _ = db.AddTask(context.Background(), storage.Task{Context: "Обновить NGINX"})
+	_, _ = db.AddTask(context.Background(), storage.Task{Context: "Написать тесты"})
+	api := New(db)
+
+	req := httptest.NewRequest(http.MethodGet, "/posts/search?q=nginx", nil)
+	w := httptest.NewRecorder()
+	api.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var results []storage.SearchResult
+	if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	// Задача с двумя вхождениями релевантнее
+	if results[0].Task.ID != 3 || results[0].Rank <= results[1].Rank {
+		t.Errorf("Results are not ranked: %+v", results)
+	}
+	want := "Настроить &lt;<mark>nginx</mark>&gt; и проверить <mark>nginx</mark>.conf"
+	if results[0].Snippet != want {
+		t.Errorf("Expected snippet %q, got %q", want, results[0].Snippet)
+	}
+	if results[1].Snippet != "Обновить <mark>NGINX</mark>" {
+		t.Errorf("Unexpected snippet %q", results[1].Snippet)
+	}
+
+	// Пустой запрос
+	req = httptest.NewRequest(http.MethodGet, "/posts/search?q=+", nil)
+	w = httptest.NewRecorder()
+	api.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for empty query, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// SlowDB - хранилище, которое отвечает только после отмены контекста
+type SlowDB struct {
+	FailingDB
+}
+
+func (s *SlowDB) Tasks(ctx context.Context, _ storage.TaskQuery) (storage.TaskPage, error) {
+	<-ctx.Done()
+	return storage.TaskPage{}, ctx.Err()
+}
+
+// Test 14: контекст запроса отменяется по истечении времени обработки
+func TestRequestTimeout(t *testing.T) {
+	api := New(&SlowDB{}, WithRequestTimeout(20*time.Millisecond))
+
+	req := httptest.NewRequest(http.MethodGet, "/posts", nil)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		api.Router().ServeHTTP(w, req)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Request was not canceled by timeout")
+	}
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status code %d, got %d", http.StatusGatewayTimeout, w.Code)
+	}
+}
+
+// PingDB - хранилище, проверка доступности которого возвращает err
+type PingDB struct {
+	FailingDB
+	pingErr error
+}
+
+func (p *PingDB) Ping(context.Context) error { return p.pingErr }
+
+// Test 15: проверки живости и готовности
+func TestHealthChecks(t *testing.T) {
+	tests := []struct {
+		name       string
+		db         storage.Interface
+		path       string
+		wantStatus int
+		wantBody   string
+		wantCheck  string
+	}{
+		{"liveness", &PingDB{pingErr: errors.New("down")}, "/healthz", http.StatusOK, "ok", ""},
+		{"ready memdb", memdb.New(), "/readyz", http.StatusOK, "ok", "ok"},
+		{"storage down", &PingDB{pingErr: errors.New("connection refused")}, "/readyz", http.StatusServiceUnavailable, "unavailable", "unavailable"},
+		{"no pinger", &MockDB{}, "/readyz", http.StatusOK, "ok", "skipped"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+			New(tt.db).Router().ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, w.Code)
+			}
+			var resp HealthResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Status != tt.wantBody {
+				t.Errorf("Expected status %q, got %q", tt.wantBody, resp.Status)
+			}
+			if got := resp.Checks["storage"].Status; got != tt.wantCheck {
+				t.Errorf("Expected storage check %q, got %q", tt.wantCheck, got)
+			}
+			if strings.Contains(w.Body.String(), "connection refused") {
+				t.Error("Storage error must not be exposed to the client")
+			}
+		})
+	}
+}
+
+// Test 16: метрики запросов и хранилища
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+	api := New(m.Storage(memdb.New()), WithMetrics(m))
+
+	for _, path := range []string{"/posts/1", "/posts/1", "/posts/999", "/unknown"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		api.Router().ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/posts/{id:[0-9]+}",status="200"} 2`,
+		`http_requests_total{method="GET",route="/posts/{id:[0-9]+}",status="404"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`storage_operation_duration_seconds_count{method="task"} 3`,
+		`storage_operation_errors_total{error="not_found",method="task"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+
+	// Метрики отдаются на отдельном адресе, а не маршрутом API
+	w = httptest.NewRecorder()
+	api.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("API /metrics: expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// Test 17: журнал запросов в JSON с ID запроса
+func TestRequestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	api := New(&FailingDB{err: errors.New("connection reset")}, WithLogger(logger))
+
+	req := httptest.NewRequest(http.MethodGet, "/posts", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	w := httptest.NewRecorder()
+	api.Router().ServeHTTP(w, req)
+
+	var entries []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var e map[string]any
+		if err := dec.Decode(&e); err != nil {
+			t.Fatalf("Log is not JSON: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected error and request log entries, got %d", len(entries))
+	}
+	storageErr, access := entries[0], entries[1]
+	if storageErr["request_id"] != "req-42" || storageErr["error"] != "connection reset" {
+		t.Errorf("Unexpected storage error entry: %v", storageErr)
+	}
+	if access["msg"] != "request" || access["request_id"] != "req-42" ||
+		access["method"] != "GET" || access["path"] != "/posts" ||
+		access["status"] != float64(http.StatusInternalServerError) ||
+		access["bytes"] != float64(w.Body.Len()) {
+		t.Errorf("Unexpected request entry: %v", access)
+	}
+	if _, ok := access["latency_ms"]; !ok {
+		t.Error("Request entry has no latency_ms")
+	}
+
+	// ID генерируется, если клиент его не передал или передал некорректный
+	for _, id := range []string{"", "bad id\r\n", strings.Repeat("x", 200)} {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.Header.Set("X-Request-ID", id)
+		w := httptest.NewRecorder()
+		api.Router().ServeHTTP(w, req)
+		if got := w.Header().Get("X-Request-ID"); got == "" || got == id {
+			t.Errorf("Expected generated request ID for %q, got %q", id, got)
+		}
+	}
+
+	// Ошибка сериализации ответа записывается с ID запроса
+	buf.Reset()
+	req = httptest.NewRequest(http.MethodGet, "/posts", nil)
+	req = req.WithContext(context.WithValue(req.Context(), loggerKey, logger.With("request_id", "req-43")))
+	w = httptest.NewRecorder()
+	writeJSON(w, req, http.StatusOK, func() {})
+	if w.Code != http.StatusInternalServerError || !strings.Contains(buf.String(), `"request_id":"req-43"`) {
+		t.Errorf("Expected encode error logged with request_id, got %d: %s", w.Code, buf.String())
+	}
+}
+
+// Test 18: трассировка запроса продолжает traceparent и включает вызовы хранилища
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	}()
+
+	api := New(tracing.Storage(memdb.New()))
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/posts/1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	api.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	server, ok := spans["GET /posts/{id:[0-9]+}"]
+	if !ok {
+		t.Fatalf("Server span not found, got %v", spans)
+	}
+	if got := server.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("Expected trace ID %s from traceparent, got %s", traceID, got)
+	}
+	call, ok := spans["storage.Task"]
+	if !ok {
+		t.Fatal("Storage span not found")
+	}
+	if call.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("Storage span is not a child of the server span")
+	}
+}
+
+// Test 19: изменение задачи требует If-Match с актуальным ETag
+func TestOptimisticConcurrency(t *testing.T) {
+	api := New(memdb.New())
+	do := func(method, ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/posts/1", strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		api.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "", "")
+	if etag := w.Header().Get("ETag"); etag != `"1"` {
+		t.Fatalf("Expected ETag \"1\", got %q", etag)
+	}
+
+	steps := []struct {
+		name       string
+		method     string
+		ifMatch    string
+		body       string
+		wantStatus int
+		wantETag   string
+	}{
+		{"put without If-Match", http.MethodPut, "", `{"context":"A"}`, http.StatusPreconditionRequired, ""},
+		{"put current version", http.MethodPut, `"1"`, `{"context":"A"}`, http.StatusOK, `"2"`},
+		{"put stale version", http.MethodPut, `"1"`, `{"context":"B"}`, http.StatusPreconditionFailed, ""},
+		{"patch stale version", http.MethodPatch, `"1"`, `{"context":"B"}`, http.StatusPreconditionFailed, ""},
+		{"patch current version", http.MethodPatch, `"2"`, `{"context":"C"}`, http.StatusOK, `"3"`},
+		{"patch any version", http.MethodPatch, "*", `{"context":"D"}`, http.StatusOK, `"4"`},
+		{"delete without If-Match", http.MethodDelete, "", "", http.StatusPreconditionRequired, ""},
+		{"delete weak ETag", http.MethodDelete, `W/"4"`, "", http.StatusPreconditionFailed, ""},
+		{"delete stale version", http.MethodDelete, `"3"`, "", http.StatusPreconditionFailed, ""},
+		{"delete current version", http.MethodDelete, `"4"`, "", http.StatusOK, ""},
+	}
+	for _, st := range steps {
+		w := do(st.method, st.ifMatch, st.body)
+		if w.Code != st.wantStatus {
+			t.Fatalf("%s: expected status code %d, got %d: %s", st.name, st.wantStatus, w.Code, w.Body.String())
+		}
+		if etag := w.Header().Get("ETag"); etag != st.wantETag {
+			t.Errorf("%s: expected ETag %q, got %q", st.name, st.wantETag, etag)
+		}
+	}
+
+	// Задача удалена последним запросом
+	if w := do(http.MethodGet, "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected task to be deleted, got status %d", w.Code)
+	}
+}
+
+// Test 20: удалённая задача попадает в корзину и восстанавливается из неё
+func TestSoftDelete(t *testing.T) {
+	db := memdb.New()
+	api := New(db)
+	do := func(method, target, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		api.Router().ServeHTTP(w, req)
+		return w
+	}
+	ids := func(target string) []int {
+		w := do(http.MethodGet, target, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected status code %d, got %d", target, http.StatusOK, w.Code)
+		}
+		var tasks []storage.Task
+		if err := json.Unmarshal(w.Body.Bytes(), &tasks); err != nil {
+			t.Fatalf("GET %s: %v", target, err)
+		}
+		var ids []int
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+
+	if w := do(http.MethodPost, "/posts/1/restore", "*"); w.Code != http.StatusNotFound {
+		t.Errorf("Restore of a live task: expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := do(http.MethodDelete, "/posts/1", `"1"`); w.Code != http.StatusOK {
+		t.Fatalf("Delete: expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if w := do(http.MethodGet, "/posts/1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Deleted task: expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if slices.Contains(ids("/posts"), 1) {
+		t.Error("Deleted task is listed in /posts")
+	}
+	if got := ids("/posts/trash"); len(got) != 1 || got[0] != 1 {
+		t.Errorf("Expected trash to contain task 1, got %v", got)
+	}
+
+	// Удаление увеличило версию задачи
+	if w := do(http.MethodPost, "/posts/1/restore", `"1"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Restore with stale ETag: expected status code %d, got %d", http.StatusPreconditionFailed, w.Code)
+	}
+	w := do(http.MethodPost, "/posts/1/restore", `"2"`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Restore: expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if etag := w.Header().Get("ETag"); etag != `"3"` {
+		t.Errorf("Restore: expected ETag \"3\", got %q", etag)
+	}
+	if !slices.Contains(ids("/posts"), 1) || len(ids("/posts/trash")) != 0 {
+		t.Error("Restored task is not back in /posts")
+	}
+
+	// Окончательно удаляются только задачи из корзины, удалённые до срока
+	if w := do(http.MethodDelete, "/posts/2", "*"); w.Code != http.StatusOK {
+		t.Fatalf("Delete: expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	ctx := context.Background()
+	if n, err := db.PurgeTasks(ctx, time.Now().Add(-time.Hour).Unix()); err != nil || n != 0 {
+		t.Errorf("Purge of recent deletions: expected 0, got %d, %v", n, err)
+	}
+	if n, err := db.PurgeTasks(ctx, time.Now().Add(time.Hour).Unix()); err != nil || n != 1 {
+		t.Errorf("Purge: expected 1 task, got %d, %v", n, err)
+	}
+	if len(ids("/posts/trash")) != 0 || !slices.Contains(ids("/posts"), 1) {
+		t.Error("Purge removed the wrong tasks")
+	}
+
+	// Окончательное удаление записывается в историю, и она сохраняется
+	entries, err := db.TaskHistory(ctx, 2)
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("Expected history of purged task, got %+v, %v", entries, err)
+	}
+	last := entries[len(entries)-1]
+	if last.Action != storage.ActionPurge || last.Version != entries[len(entries)-2].Version+1 {
+		t.Errorf("Unexpected purge entry %+v", last)
+	}
+	if c, ok := last.Changes["context"]; !ok || c.Before == nil || c.After != nil {
+		t.Errorf("Purge: unexpected context change %+v", c)
+	}
+}
+
+// Test 21: изменения задачи записываются в историю
+func TestTaskHistory(t *testing.T) {
+	db := memdb.New()
+	api := New(db)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("If-Match", "*")
+		w := httptest.NewRecorder()
+		api.Router().ServeHTTP(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("%s %s: unexpected status code %d: %s", method, target, w.Code, w.Body.String())
+		}
+		return w
+	}
+	do(http.MethodPost, "/posts", `{"responsible_id":1,"responsible_name":"Alice","context":"Draft"}`)
+	do(http.MethodPatch, "/posts/3", `{"context":"Final"}`)
+	do(http.MethodDelete, "/posts/3", "")
+	do(http.MethodPost, "/posts/3/restore", "")
+
+	var entries []storage.HistoryEntry
+	if err := json.Unmarshal(do(http.MethodGet, "/posts/3/history", "").Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	actions := []string{storage.ActionCreate, storage.ActionUpdate, storage.ActionDelete, storage.ActionRestore}
+	if len(entries) != len(actions) {
+		t.Fatalf("Expected %d history entries, got %+v", len(actions), entries)
+	}
+	for i, e := range entries {
+		if e.Action != actions[i] || e.Version != i+1 || e.TaskID != 3 || e.Actor != storage.AnonymousActor {
+			t.Errorf("Unexpected history entry %d: %+v", i, e)
+		}
+	}
+	if c := entries[0].Changes["context"]; c.Before != nil || c.After != "Draft" {
+		t.Errorf("Create: unexpected context change %+v", c)
+	}
+	want := map[string]storage.Change{"context": {Before: "Draft", After: "Final"}}
+	if fmt.Sprint(entries[1].Changes) != fmt.Sprint(want) {
+		t.Errorf("Update: expected changes %v, got %v", want, entries[1].Changes)
+	}
+	if c, ok := entries[2].Changes["deleted_at"]; !ok || c.Before != nil || c.After == nil {
+		t.Errorf("Delete: unexpected deleted_at change %+v", c)
+	}
+
+	// Автор изменения берётся из контекста
+	ctx := storage.WithActor(context.Background(), "alice")
+	task, err := db.AddTask(ctx, storage.Task{ResponsibleID: 1, ResponsibleName: "Alice", Context: "Mine"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.TaskHistory(ctx, task.ID); len(got) != 1 || got[0].Actor != "alice" {
+		t.Errorf("Expected one entry by alice, got %+v", got)
+	}
+
+	// Задача без истории и несуществующая задача
+	if w := do(http.MethodGet, "/posts/1/history", ""); strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("Expected empty history for task 1, got %s", w.Body.String())
+	}
+	req := httptest.NewRequest(http.MethodGet, "/posts/999/history", nil)
+	w := httptest.NewRecorder()
+	api.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// Test 22: запросы к API требуют действительный JWT
+func TestAuthentication(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"k1","use":"sig","alg":"RS256","n":%q,"e":%q}]}`,
+		base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()))
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(jwksFile, []byte(jwks), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := auth.New(auth.WithSecret(secret), auth.WithJWKSFile(jwksFile), auth.WithIssuer("news-idp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := memdb.New()
+	api := New(db, WithAuth(verifier))
+
+	claims := func(sub string, exp time.Duration) jwt.MapClaims {
+		return jwt.MapClaims{"sub": sub, "iss": "news-idp", "exp": time.Now().Add(exp).Unix()}
+	}
+	sign := func(method jwt.SigningMethod, key any, kid string, c jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, c)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	otherIssuer := claims("alice", time.Hour)
+	otherIssuer["iss"] = "evil"
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		wantStatus    int
+	}{
+		{"no token", "/posts", "", http.StatusUnauthorized},
+		{"not bearer", "/posts", "Basic YWxpY2U6c2VjcmV0", http.StatusUnauthorized},
+		{"malformed", "/posts", "Bearer not-a-jwt", http.StatusUnauthorized},
+		{"HS256", "/posts", "Bearer " + sign(jwt.SigningMethodHS256, secret, "", claims("alice", time.Hour)), http.StatusOK},
+		{"HS256 wrong secret", "/posts", "Bearer " + sign(jwt.SigningMethodHS256, []byte("another-secret-another-secret-32"), "", claims("alice", time.Hour)), http.StatusUnauthorized},
+		{"expired", "/posts", "Bearer " + sign(jwt.SigningMethodHS256, secret, "", claims("alice", -time.Hour)), http.StatusUnauthorized},
+		{"no subject", "/posts", "Bearer " + sign(jwt.SigningMethodHS256, secret, "", claims("", time.Hour)), http.StatusUnauthorized},
+		{"wrong issuer", "/posts", "Bearer " + sign(jwt.SigningMethodHS256, secret, "", otherIssuer), http.StatusUnauthorized},
+		{"RS256", "/posts", "Bearer " + sign(jwt.SigningMethodRS256, rsaKey, "k1", claims("bob", time.Hour)), http.StatusOK},
+		{"RS256 unknown kid", "/posts", "Bearer " + sign(jwt.SigningMethodRS256, rsaKey, "k2", claims("bob", time.Hour)), http.StatusUnauthorized},
+		{"health without token", "/healthz", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		w := httptest.NewRecorder()
+		api.Router().ServeHTTP(w, req)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: expected status code %d, got %d", tt.name, tt.wantStatus, w.Code)
+		}
+		if w.Code == http.StatusUnauthorized {
+			var resp ErrorResponse
+			_ = json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Error.Code != codeUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("%s: unexpected 401 response %s", tt.name, w.Body.String())
+			}
+		}
+	}
+
+	// Вызывающий становится автором изменения в истории
+	manager := claims("bob", time.Hour)
+	manager["roles"] = []string{auth.RoleManager}
+	req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(`{"responsible_id":1,"responsible_name":"Bob","context":"Signed"}`))
+	req.Header.Set("Authorization", "Bearer "+sign(jwt.SigningMethodRS256, rsaKey, "k1", manager))
+	w := httptest.NewRecorder()
+	api.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+	}
+	entries, err := db.TaskHistory(context.Background(), 3)
+	if err != nil || len(entries) != 1 || entries[0].Actor != "bob" {
+		t.Errorf("Expected history entry by bob, got %+v, %v", entries, err)
+	}
+}
+
+// Test 23: права зависят от роли и ответственного за задачу
+func TestAuthorization(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	verifier, err := auth.New(auth.WithSecret(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := New(memdb.New(), WithAuth(verifier))
+	token := func(sub string, roles ...string) string {
+		c := jwt.MapClaims{"sub": sub, "roles": roles, "exp": time.Now().Add(time.Hour).Unix()}
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	// Исполнитель 10 отвечает за задачу 1, задача 2 - пользователя 11
+	assignee := token("10", auth.RoleAssignee)
+	manager := token("20", auth.RoleManager)
+	admin := token("30", auth.RoleAdmin)
+	nobody := token("40")
+
+	tests := []struct {
+		name       string
+		token      string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"anyone reads", nobody, http.MethodGet, "/posts/2", "", http.StatusOK},
+		{"no role edits", nobody, http.MethodPatch, "/posts/1", `{"context":"A"}`, http.StatusForbidden},
+		{"assignee edits own task", assignee, http.MethodPatch, "/posts/1", `{"context":"A"}`, http.StatusOK},
+		{"assignee edits other task", assignee, http.MethodPatch, "/posts/2", `{"context":"A"}`, http.StatusForbidden},
+		{"assignee replaces own task", assignee, http.MethodPut, "/posts/1", `{"responsible_id":10,"context":"B"}`, http.StatusOK},
+		{"assignee replaces other task", assignee, http.MethodPut, "/posts/2", `{"responsible_id":10,"context":"B"}`, http.StatusForbidden},
+		{"assignee reassigns", assignee, http.MethodPut, "/posts/1", `{"responsible_id":11,"context":"B"}`, http.StatusForbidden},
+		{"assignee creates own task", assignee, http.MethodPost, "/posts", `{"responsible_id":10,"context":"C"}`, http.StatusCreated},
+		{"assignee creates other task", assignee, http.MethodPost, "/posts", `{"responsible_id":11,"context":"C"}`, http.StatusForbidden},
+		{"assignee deletes", assignee, http.MethodDelete, "/posts/1", "", http.StatusForbidden},
+		{"manager reassigns", manager, http.MethodPatch, "/posts/1", `{"responsible_id":11}`, http.StatusOK},
+		{"assignee lost task", assignee, http.MethodPatch, "/posts/1", `{"context":"D"}`, http.StatusForbidden},
+		{"manager deletes", manager, http.MethodDelete, "/posts/2", "", http.StatusForbidden},
+		{"admin deletes", admin, http.MethodDelete, "/posts/2", "", http.StatusOK},
+		{"manager lists trash", manager, http.MethodGet, "/posts/trash", "", http.StatusForbidden},
+		{"admin lists trash", admin, http.MethodGet, "/posts/trash", "", http.StatusOK},
+		{"manager restores", manager, http.MethodPost, "/posts/2/restore", "", http.StatusForbidden},
+		{"admin restores", admin, http.MethodPost, "/posts/2/restore", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		req.Header.Set("If-Match", "*")
+		w := httptest.NewRecorder()
+		api.Router().ServeHTTP(w, req)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: expected status code %d, got %d: %s", tt.name, tt.wantStatus, w.Code, w.Body.String())
+			continue
+		}
+		if w.Code == http.StatusForbidden {
+			var resp ErrorResponse
+			_ = json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Error.Code != codeForbidden {
+				t.Errorf("%s: expected error code %q, got %q", tt.name, codeForbidden, resp.Error.Code)
+			}
+		}
+	}
+}
+
+// Test 24: сервисные клиенты аутентифицируются ключом API
+func TestAPIKeys(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	verifier, err := auth.New(auth.WithSecret(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := memdb.New()
+	api := New(db, WithAuth(verifier))
+	token := func(sub string, roles ...string) string {
+		c := jwt.MapClaims{"sub": sub, "roles": roles, "exp": time.Now().Add(time.Hour).Unix()}
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + s
+	}
+	admin := token("root", auth.RoleAdmin)
+	do := func(method, path, header, value, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(header, value)
+		req.Header.Set("If-Match", "*")
+		w := httptest.NewRecorder()
+		api.Router().ServeHTTP(w, req)
+		return w
+	}
+	create := func(body string) CreatedAPIKey {
+		w := do(http.MethodPost, "/api-keys", "Authorization", admin, body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Create key: expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		var k CreatedAPIKey
+		if err := json.Unmarshal(w.Body.Bytes(), &k); err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	writer := create(`{"name":"ci","scopes":["read","write"]}`)
+	reader := create(`{"name":"bot","scopes":["read"]}`)
+	if !strings.HasPrefix(writer.Key, "gn_") || writer.CreatedBy != "root" || writer.ID != 1 {
+		t.Errorf("Unexpected created key %+v", writer)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		key        string
+		body       string
+		wantStatus int
+	}{
+		{"read with read key", http.MethodGet, "/posts", reader.Key, "", http.StatusOK},
+		{"edit with read key", http.MethodPatch, "/posts/1", reader.Key, `{"context":"A"}`, http.StatusForbidden},
+		{"edit with write key", http.MethodPatch, "/posts/1", writer.Key, `{"context":"A"}`, http.StatusOK},
+		{"delete with write key", http.MethodDelete, "/posts/1", writer.Key, "", http.StatusForbidden},
+		{"manage keys with write key", http.MethodGet, "/api-keys", writer.Key, "", http.StatusForbidden},
+		{"unknown key", http.MethodGet, "/posts", "gn_unknown", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if w := do(tt.method, tt.path, "X-API-Key", tt.key, tt.body); w.Code != tt.wantStatus {
+			t.Errorf("%s: expected status code %d, got %d: %s", tt.name, tt.wantStatus, w.Code, w.Body.String())
+		}
+	}
+
+	// Изменение записано от имени ключа, время использования обновлено
+	if entries, _ := db.TaskHistory(context.Background(), 1); len(entries) != 1 || entries[0].Actor != "apikey:1" {
+		t.Errorf("Expected history entry by apikey:1, got %+v", entries)
+	}
+	w := do(http.MethodGet, "/api-keys", "Authorization", admin, "")
+	var keys []storage.APIKey
+	if err := json.Unmarshal(w.Body.Bytes(), &keys); err != nil || len(keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %s", w.Body.String())
+	}
+	if keys[0].LastUsedAt == nil || strings.Contains(w.Body.String(), writer.Key) || strings.Contains(w.Body.String(), auth.HashAPIKey(writer.Key)) {
+		t.Errorf("Unexpected key list %s", w.Body.String())
+	}
+
+	// Отозванный ключ больше не принимается
+	if w := do(http.MethodDelete, "/api-keys/1", "Authorization", admin, ""); w.Code != http.StatusOK {
+		t.Fatalf("Revoke: expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if w := do(http.MethodGet, "/posts", "X-API-Key", writer.Key, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Revoked key: expected status code %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := do(http.MethodDelete, "/api-keys/99", "Authorization", admin, ""); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), `"API key not found"`) {
+		t.Errorf("Revoke unknown key: expected status code %d with API key message, got %d: %s", http.StatusNotFound, w.Code, w.Body.String())
+	}
+
+	// Ключи создаёт только администратор, области доступа проверяются
+	if w := do(http.MethodPost, "/api-keys", "Authorization", token("bob", auth.RoleManager), `{"name":"x","scopes":["read"]}`); w.Code != http.StatusForbidden {
+		t.Errorf("Create key as manager: expected status code %d, got %d", http.StatusForbidden, w.Code)
+	}
+	if w := do(http.MethodPost, "/api-keys", "Authorization", admin, `{"name":"x","scopes":["root"]}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Unknown scope: expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+}
+
+// Test 25: частота запросов ограничивается для каждого клиента
+func TestRateLimit(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	verifier, err := auth.New(auth.WithSecret(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := New(memdb.New(),
+		WithAuth(verifier),
+		WithRateLimits(map[string]ratelimit.Limit{
+			ratelimit.Default: {Requests: 2, Period: time.Minute},
+			"GET /posts":      {},
+		}),
+		WithTrustedProxies([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}),
+	)
+	token := func(sub string) string {
+		c := jwt.MapClaims{"sub": sub, "exp": time.Now().Add(time.Hour).Unix()}
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + s
+	}
+	do := func(path, remote, xff, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remote
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		api.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	alice, bob := token("alice"), token("bob")
+	for i, remaining := range []string{"1", "0"} {
+		w := do("/posts/999", "203.0.113.1:1234", "", alice)
+		if w.Code != http.StatusNotFound || w.Header().Get("RateLimit-Remaining") != remaining {
+			t.Fatalf("Request %d: got status %d, RateLimit-Remaining %q", i+1, w.Code, w.Header().Get("RateLimit-Remaining"))
+		}
+	}
+	w := do("/posts/999", "203.0.113.1:1234", "", alice)
+	if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), `"rate_limited"`) {
+		t.Fatalf("Expected 429 rate_limited, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") != "30" || w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Policy") != "2;w=60" {
+		t.Errorf("Unexpected rate limit headers: %v", w.Header())
+	}
+	// Другой пользователь с того же адреса ограничивается отдельно
+	if w := do("/posts/999", "203.0.113.1:1234", "", bob); w.Code != http.StatusNotFound {
+		t.Errorf("Expected bob's request to pass, got %d", w.Code)
+	}
+	// Лимит маршрута отключён
+	if w := do("/posts", "203.0.113.1:1234", "", alice); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("Expected unlimited GET /posts, got %d, %v", w.Code, w.Header())
+	}
+	// Служебные пути не ограничиваются
+	for range 3 {
+		if w := do("/healthz", "203.0.113.1:1234", "", ""); w.Code != http.StatusOK {
+			t.Fatalf("Expected /healthz to pass, got %d", w.Code)
+		}
+	}
+
+	// Неаутентифицированные клиенты различаются по IP. X-Forwarded-For от
+	// недоверенного адреса игнорируется.
+	for range 2 {
+		do("/posts/trash", "203.0.113.2:1234", "198.51.100.1", "")
+	}
+	if w := do("/posts/trash", "203.0.113.2:1234", "198.51.100.2", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected spoofed X-Forwarded-For to be ignored, got %d", w.Code)
+	}
+	// От доверенного прокси клиент - первый недоверенный адрес справа
+	for range 2 {
+		do("/posts/trash", "10.0.0.5:80", "192.0.2.1, 198.51.100.3, 10.0.0.7", "")
+	}
+	if w := do("/posts/trash", "10.0.0.5:80", "192.0.2.2, 198.51.100.3", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected client 198.51.100.3 to be limited, got %d", w.Code)
+	}
+	if w := do("/posts/trash", "10.0.0.5:80", "198.51.100.4", ""); w.Code == http.StatusTooManyRequests {
+		t.Error("Expected client 198.51.100.4 to have its own bucket")
+	}
+
+	// Лимит маршрута задаётся без выражений переменных шаблона
+	limited := New(memdb.New(), WithRateLimits(map[string]ratelimit.Limit{
+		"GET /posts/{id}": {Requests: 1, Period: time.Minute},
+	}))
+	if err := limited.CheckRateLimits(); err != nil {
+		t.Fatalf("CheckRateLimits() error = %v", err)
+	}
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		limited.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/1", nil))
+		if w.Code != want {
+			t.Errorf("GET /posts/{id} request %d: expected status code %d, got %d", i+1, want, w.Code)
+		}
+	}
+	// Лимиты неизвестных маршрутов отклоняются при запуске
+	for _, route := range []string{"PUT /post/{id}", "GET /posts/{id}/comments", "GET /api-keys"} {
+		api := New(memdb.New(), WithRateLimits(map[string]ratelimit.Limit{route: {Requests: 1, Period: time.Minute}}))
+		if err := api.CheckRateLimits(); err == nil || !strings.Contains(err.Error(), route) {
+			t.Errorf("CheckRateLimits() for %q = %v, want unknown route error", route, err)
+		}
+	}
+}
+
+// Test 26: браузерные клиенты с разрешённых источников получают заголовки CORS
+func TestCORS(t *testing.T) {
+	api := New(memdb.New(), WithCORS(CORS{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders:   []string{"Authorization", "If-Match"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}))
+	do := func(method, path, origin string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		api.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	// Preflight не доходит до обработчика
+	w := do(http.MethodOptions, "/posts", "https://app.example.com", "Access-Control-Request-Method", "POST")
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Fatalf("Preflight: expected status code %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, POST",
+		"Access-Control-Allow-Headers":     "Authorization, If-Match",
+		"Access-Control-Max-Age":           "600",
+	}
+	for k, v := range want {
+		if got := w.Header().Get(k); got != v {
+			t.Errorf("Preflight: expected %s %q, got %q", k, v, got)
+		}
+	}
+	if !slices.Contains(w.Header().Values("Vary"), "Origin") {
+		t.Errorf("Preflight: expected Vary: Origin, got %v", w.Header().Values("Vary"))
+	}
+
+	if w := do(http.MethodOptions, "/posts", "https://evil.example.com", "Access-Control-Request-Method", "POST"); w.Code != http.StatusForbidden ||
+		w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Preflight from unknown origin: expected status code %d without CORS headers, got %d", http.StatusForbidden, w.Code)
+	}
+
+	w = do(http.MethodGet, "/posts", "https://app.example.com")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		!strings.Contains(w.Header().Get("Access-Control-Expose-Headers"), "X-Total-Count") {
+		t.Errorf("GET: unexpected status %d or CORS headers %v", w.Code, w.Header())
+	}
+	// Ошибки тоже читаются скриптом
+	if w := do(http.MethodGet, "/posts/999", "https://app.example.com"); w.Code != http.StatusNotFound ||
+		w.Header().Get("Access-Control-Allow-Origin") == "" {
+		t.Errorf("404: expected CORS headers, got %d %v", w.Code, w.Header())
+	}
+	if w := do(http.MethodGet, "/posts", "https://evil.example.com"); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Unknown origin: expected no Access-Control-Allow-Origin, got %q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+	// OPTIONS без preflight больше не попадает в обработчики
+	if w := do(http.MethodOptions, "/posts", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Plain OPTIONS: expected status code %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+// testCert выпускает сертификат по шаблону tmpl, подписанный parent (nil -
+// самоподписанный), и записывает его и ключ в PEM-файлы dir/name.crt и .key.
+func testCert(t *testing.T, dir, name string, tmpl *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore, tmpl.NotAfter = time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(path string, block *pem.Block) {
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(dir, name+".crt"), &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	write(filepath.Join(dir, name+".key"), &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return cert, key
+}
+
+// Test 27: сервер обслуживает HTTPS с mTLS и перечитывает сертификаты
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	caTmpl := func(cn string) *x509.Certificate {
+		return &x509.Certificate{
+			Subject:               pkix.Name{CommonName: cn},
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}
+	}
+	leaf := func(cn string, usage x509.ExtKeyUsage) *x509.Certificate {
+		return &x509.Certificate{
+			Subject:     pkix.Name{CommonName: cn},
+			DNSNames:    []string{"localhost"},
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{usage},
+		}
+	}
+	ca, caKey := testCert(t, dir, "ca", caTmpl("test CA"), nil, nil)
+	testCert(t, dir, "server", leaf("server-1", x509.ExtKeyUsageServerAuth), ca, caKey)
+	testCert(t, dir, "client", leaf("internal-client", x509.ExtKeyUsageClientAuth), ca, caKey)
+	otherCA, otherKey := testCert(t, dir, "other-ca", caTmpl("other CA"), nil, nil)
+	testCert(t, dir, "stranger", leaf("stranger", x509.ExtKeyUsageClientAuth), otherCA, otherKey)
+
+	reloader, err := certs.NewReloader(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := &http.Server{
+		Handler:   New(memdb.New()).Router(),
+		ErrorLog:  log.New(io.Discard, "", 0),
+		TLSConfig: reloader.TLSConfig(certs.ClientAuthRequire),
+	}
+	go hs.ServeTLS(ln, "", "")
+	defer hs.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	get := func(clientCert string) (*http.Response, error) {
+		cfg := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+		if clientCert != "" {
+			pair, err := tls.LoadX509KeyPair(filepath.Join(dir, clientCert+".crt"), filepath.Join(dir, clientCert+".key"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.Certificates = []tls.Certificate{pair}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+		resp, err := client.Get("https://" + ln.Addr().String() + "/healthz")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	resp, err := get("client")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Client with certificate: expected status code %d, got %v, %v", http.StatusOK, resp, err)
+	}
+	if cn := resp.TLS.PeerCertificates[0].Subject.CommonName; cn != "server-1" {
+		t.Errorf("Expected server certificate server-1, got %s", cn)
+	}
+	if _, err := get(""); err == nil {
+		t.Error("Expected client without certificate to be rejected")
+	}
+	if _, err := get("stranger"); err == nil {
+		t.Error("Expected client certificate from another CA to be rejected")
+	}
+
+	// Сертификат сервера заменяется без перезапуска
+	if reloaded, err := reloader.Reload(); err != nil || reloaded {
+		t.Errorf("Reload() without changes = %v, %v; want false, nil", reloaded, err)
+	}
+	testCert(t, dir, "server", leaf("server-2", x509.ExtKeyUsageServerAuth), ca, caKey)
+	// Время изменения файла может совпасть с прошлой записью
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(dir, "server.crt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := reloader.Reload(); err != nil || !reloaded {
+		t.Fatalf("Reload() = %v, %v; want true, nil", reloaded, err)
+	}
+	resp, err = get("client")
+	if err != nil || resp.TLS.PeerCertificates[0].Subject.CommonName != "server-2" {
+		t.Fatalf("Expected reloaded certificate server-2, got %v, %v", resp, err)
+	}
+
+	// Повреждённый файл не заменяет рабочий сертификат
+	if err := os.WriteFile(filepath.Join(dir, "server.crt"), []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloader.Reload(); err == nil {
+		t.Error("Expected Reload() error for broken certificate")
+	}
+	if resp, err := get("client"); err != nil || resp.TLS.PeerCertificates[0].Subject.CommonName != "server-2" {
+		t.Errorf("Expected server to keep certificate server-2, got %v, %v", resp, err)
+	}
+}
+
+// Test 28: без JWT API требует ключ API, если включены только ключи
+func TestAPIKeysWithoutJWT(t *testing.T) {
+	db := memdb.New()
+	api := New(db, WithAPIKeys())
+	addKey := func(scopes ...string) string {
+		key, hash, err := auth.NewAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.AddAPIKey(context.Background(), storage.APIKey{Name: "bootstrap", Scopes: scopes, Hash: hash}); err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	admin, reader := addKey(storage.ScopeAdmin), addKey(storage.ScopeRead)
+	do := func(method, path, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"context": "task", "responsible_id": 1}`))
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		api.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/posts", "", "")
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `APIKey realm="go-news"` {
+		t.Errorf("No key: expected status code %d with APIKey challenge, got %d %q", http.StatusUnauthorized, w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+	if w := do(http.MethodGet, "/posts", "Authorization", "Bearer some.jwt.token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Bearer token: expected status code %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := do(http.MethodGet, "/posts", "X-API-Key", reader); w.Code != http.StatusOK {
+		t.Errorf("Read key: expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if w := do(http.MethodPost, "/posts", "X-API-Key", reader); w.Code != http.StatusForbidden {
+		t.Errorf("Read key POST: expected status code %d, got %d", http.StatusForbidden, w.Code)
+	}
+	if w := do(http.MethodGet, "/api-keys", "X-API-Key", admin); w.Code != http.StatusOK {
+		t.Errorf("Admin key GET /api-keys: expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if w := do(http.MethodGet, "/healthz", "", ""); w.Code != http.StatusOK {
+		t.Errorf("/healthz: expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+// Test 29: сбой записи истории не превращает применённое изменение в ошибку
+func TestHistoryNotRecorded(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	db := &HistoryFailingDB{Interface: memdb.New()}
+	api := New(db, WithLogger(logger))
+
+	req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(`{"responsible_id":1,"responsible_name":"Alice","context":"Draft"}`))
+	w := httptest.NewRecorder()
+	api.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/posts/3" {
+		t.Fatalf("Create: expected status code %d for /posts/3, got %d %q", http.StatusCreated, w.Code, w.Header().Get("Location"))
+	}
+	req = httptest.NewRequest(http.MethodPatch, "/posts/3", strings.NewReader(`{"context":"Final"}`))
+	req.Header.Set("If-Match", `"1"`)
+	w = httptest.NewRecorder()
+	api.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("Update: expected status code %d with ETag \"2\", got %d %q", http.StatusOK, w.Code, w.Header().Get("ETag"))
+	}
+	if got := strings.Count(buf.String(), "task change not recorded in history"); got != 2 {
+		t.Errorf("Expected 2 logged history errors, got %d: %s", got, buf.String())
+	}
+	if !strings.Contains(buf.String(), `"request_id"`) {
+		t.Errorf("Expected history error to be logged with request_id: %s", buf.String())
+	}
+}
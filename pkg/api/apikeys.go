@@ -0,0 +1,132 @@
+package api
+
+import (
+	"fmt"
+	"go-news/pkg/auth"
+	"go-news/pkg/storage"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// maxAPIKeyNameLength - максимальная длина имени ключа API.
+const maxAPIKeyNameLength = 255
+
+// apiKeyRequest - тело запроса POST /api-keys.
+type apiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreatedAPIKey - ответ на создание ключа API. Key возвращается только
+// в этом ответе: хранилище знает лишь хеш ключа.
+type CreatedAPIKey struct {
+	storage.APIKey
+	Key string `json:"key"`
+}
+
+// apiKeysHandler возвращает все ключи API, в том числе отозванные.
+func (api *API) apiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if err := api.requireRole(r, auth.RoleAdmin); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	keys, err := api.db.APIKeys(r.Context())
+	if err != nil {
+		writeResourceError(w, r, "API key", err)
+		return
+	}
+	if keys == nil {
+		keys = []storage.APIKey{}
+	}
+	writeJSON(w, r, http.StatusOK, keys)
+}
+
+// addAPIKeyHandler создаёт ключ API с именем и областями доступа из тела запроса.
+func (api *API) addAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if err := api.requireRole(r, auth.RoleAdmin); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var req apiKeyRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	key, hash, err := auth.NewAPIKey()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+	k, err := api.db.AddAPIKey(r.Context(), storage.APIKey{
+		Name:      strings.TrimSpace(req.Name),
+		Scopes:    req.Scopes,
+		Hash:      hash,
+		CreatedBy: caller.Subject,
+		CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		writeResourceError(w, r, "API key", err)
+		return
+	}
+	w.Header().Set("Location", "/api-keys/"+strconv.Itoa(k.ID))
+	writeJSON(w, r, http.StatusCreated, CreatedAPIKey{APIKey: k, Key: key})
+}
+
+// revokeAPIKeyHandler отзывает ключ API. Отозванный ключ остаётся в списке.
+func (api *API) revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if err := api.requireRole(r, auth.RoleAdmin); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, badRequest("invalid API key id"))
+		return
+	}
+	err = api.db.RevokeAPIKey(r.Context(), id)
+	if err != nil {
+		writeResourceError(w, r, "API key", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// validate проверяет имя и области доступа ключа.
+func (req *apiKeyRequest) validate() error {
+	var fields []FieldError
+	switch name := strings.TrimSpace(req.Name); {
+	case name == "":
+		fields = append(fields, FieldError{Field: "name", Message: "must not be empty"})
+	case utf8.RuneCountInString(name) > maxAPIKeyNameLength:
+		fields = append(fields, FieldError{Field: "name", Message: fmt.Sprintf("must be at most %d characters", maxAPIKeyNameLength)})
+	}
+	if len(req.Scopes) == 0 {
+		fields = append(fields, FieldError{Field: "scopes", Message: "must not be empty"})
+	}
+	for _, scope := range req.Scopes {
+		switch scope {
+		case storage.ScopeRead, storage.ScopeWrite, storage.ScopeAdmin:
+		default:
+			fields = append(fields, FieldError{Field: "scopes", Message: "unknown scope " + strconv.Quote(scope)})
+		}
+	}
+	if len(fields) > 0 {
+		e := newError(http.StatusUnprocessableEntity, codeValidation, "API key validation failed")
+		e.Fields = fields
+		return e
+	}
+	slices.Sort(req.Scopes)
+	req.Scopes = slices.Compact(req.Scopes)
+	return nil
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"go-news/pkg/auth"
+	"go-news/pkg/storage"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// attributeSubject - атрибут span с идентификатором вызывающего.
+const attributeSubject = attribute.Key("enduser.id")
+
+// apiKeyTouchInterval - не чаще этого время последнего использования ключа
+// API записывается в хранилище, чтобы не изменять его на каждый запрос.
+const apiKeyTouchInterval = time.Minute
+
+// publicPaths - пути, доступные без аутентификации и без лимита запросов:
+// проверки оркестратора не передают ни токен, ни ключ API.
+var publicPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// authMiddleware требует ключ API в заголовке X-API-Key или JWT в заголовке
+// Authorization: Bearer (если задан api.auth) и сохраняет личность
+// вызывающего в контексте запроса. Она же становится автором изменений в
+// истории задач. Пути из publicPaths доступны без аутентификации.
+func (api *API) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		id, err := api.authenticate(r)
+		if err != nil {
+			// Неудачные попытки расходуют лимит IP-адреса: иначе подбор
+			// ключей и токенов ничем не ограничен.
+			if !api.allow(w, r) {
+				return
+			}
+			var e *Error
+			if errors.As(err, &e) && e.status == http.StatusUnauthorized {
+				scheme := "APIKey"
+				if api.auth != nil {
+					scheme = "Bearer"
+				}
+				w.Header().Set("WWW-Authenticate", scheme+` realm="go-news"`)
+			}
+			writeError(w, r, err)
+			return
+		}
+
+		trace.SpanFromContext(r.Context()).SetAttributes(attributeSubject.String(id.Subject))
+		ctx := auth.WithIdentity(r.Context(), id)
+		ctx = storage.WithActor(ctx, id.Subject)
+		ctx = context.WithValue(ctx, loggerKey, loggerFrom(ctx).With("subject", id.Subject))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticate определяет вызывающего по ключу API или JWT.
+func (api *API) authenticate(r *http.Request) (auth.Identity, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return api.apiKeyIdentity(r.Context(), key)
+	}
+	if api.auth == nil {
+		return auth.Identity{}, unauthorized("missing API key")
+	}
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return auth.Identity{}, unauthorized("missing bearer token or API key")
+	}
+	id, err := api.auth.Verify(token)
+	if err != nil {
+		loggerFrom(r.Context()).Debug("token rejected", "error", err)
+		return auth.Identity{}, unauthorized("invalid bearer token")
+	}
+	return id, nil
+}
+
+// apiKeyIdentity находит ключ API по хешу и отмечает его использование.
+func (api *API) apiKeyIdentity(ctx context.Context, key string) (auth.Identity, error) {
+	k, err := api.db.APIKeyByHash(ctx, auth.HashAPIKey(key))
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return auth.Identity{}, unauthorized("invalid API key")
+	case err != nil:
+		return auth.Identity{}, err
+	case k.RevokedAt != nil:
+		return auth.Identity{}, unauthorized("API key has been revoked")
+	}
+
+	now := time.Now()
+	if k.LastUsedAt == nil || now.Sub(time.Unix(*k.LastUsedAt, 0)) >= apiKeyTouchInterval {
+		if err := api.db.TouchAPIKey(ctx, k.ID, now.Unix()); err != nil {
+			loggerFrom(ctx).Warn("failed to record API key use", "api_key_id", k.ID, "error", err)
+		}
+	}
+	return keyIdentity(k), nil
+}
+
+// keyIdentity возвращает вызывающего с ключом k: область admin даёт роль
+// администратора, write - менеджера, read - только чтение.
+func keyIdentity(k storage.APIKey) auth.Identity {
+	id := auth.Identity{Subject: "apikey:" + strconv.Itoa(k.ID)}
+	for _, scope := range k.Scopes {
+		switch scope {
+		case storage.ScopeAdmin:
+			id.Roles = append(id.Roles, auth.RoleAdmin)
+		case storage.ScopeWrite:
+			id.Roles = append(id.Roles, auth.RoleManager)
+		}
+	}
+	return id
+}
+
+// unauthorized создаёт ошибку 401 с сообщением для клиента.
+func unauthorized(message string) *Error {
+	return newError(http.StatusUnauthorized, codeUnauthorized, message)
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"go-news/pkg/auth"
+	"go-news/pkg/storage"
+	"net/http"
+)
+
+// caller возвращает вызывающего, права которого нужно проверить, или false,
+// если аутентификация отключена: тогда все действия разрешены.
+func (api *API) caller(r *http.Request) (auth.Identity, bool) {
+	if !api.authRequired {
+		return auth.Identity{}, false
+	}
+	id, _ := auth.FromContext(r.Context())
+	return id, true
+}
+
+// forbidden создаёт ошибку 403 с сообщением для клиента.
+func forbidden(message string) *Error {
+	return newError(http.StatusForbidden, codeForbidden, message)
+}
+
+// requireRole разрешает действие вызывающему с одной из ролей roles.
+func (api *API) requireRole(r *http.Request, roles ...string) error {
+	id, ok := api.caller(r)
+	if ok && !id.HasRole(roles...) {
+		return forbidden("insufficient role")
+	}
+	return nil
+}
+
+// checkCreate проверяет право вызывающего создать задачу t: исполнитель
+// может создать задачу только на себя.
+func checkCreate(id auth.Identity, t storage.Task) error {
+	switch {
+	case id.HasRole(auth.RoleAdmin, auth.RoleManager):
+		return nil
+	case !id.HasRole(auth.RoleAssignee):
+		return forbidden("insufficient role")
+	case !owns(id, t):
+		return forbidden("assignees may only create tasks assigned to themselves")
+	}
+	return nil
+}
+
+// checkUpdate проверяет право вызывающего заменить задачу current на
+// updated: исполнитель может изменять только свои задачи и не может
+// назначить их другому.
+func checkUpdate(id auth.Identity, current, updated storage.Task) error {
+	switch {
+	case id.HasRole(auth.RoleAdmin, auth.RoleManager):
+		return nil
+	case !id.HasRole(auth.RoleAssignee):
+		return forbidden("insufficient role")
+	case !owns(id, current):
+		return forbidden("task is assigned to another user")
+	case updated.ResponsibleID != current.ResponsibleID:
+		return forbidden("only managers may reassign tasks")
+	}
+	return nil
+}
+
+// owns сообщает, что вызывающий - ответственный за задачу t.
+func owns(id auth.Identity, t storage.Task) bool {
+	return id.UserID != 0 && t.ResponsibleID == id.UserID
+}
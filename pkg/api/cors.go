@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS - правила доступа к API из браузера со страниц других источников.
+type CORS struct {
+	// AllowedOrigins - разрешённые источники (схема, хост и порт); "*" -
+	// любой источник.
+	AllowedOrigins []string
+	// AllowedMethods и AllowedHeaders - методы и заголовки, которые
+	// браузер может использовать в запросе.
+	AllowedMethods []string
+	AllowedHeaders []string
+	// AllowCredentials разрешает запросы с cookie и Authorization от
+	// браузера. Несовместим с источником "*".
+	AllowCredentials bool
+	// MaxAge - сколько браузер может кешировать ответ на preflight-запрос;
+	// 0 - не указывается.
+	MaxAge time.Duration
+}
+
+// exposedHeaders - заголовки ответа, которые браузер открывает скриптам
+// другого источника помимо стандартных.
+var exposedHeaders = strings.Join([]string{
+	"ETag", "Location", "X-Request-ID", "X-Total-Count", "X-Next-Cursor",
+	"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After",
+}, ", ")
+
+// corsMiddleware добавляет заголовки CORS к ответам на запросы с разрешённых
+// источников и отвечает на preflight-запросы, не передавая их маршрутизатору:
+// они не аутентифицируются и не расходуют лимит запросов.
+func (api *API) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		h := w.Header()
+		// Ответ зависит от источника: кеши не должны отдавать его другому.
+		h.Add("Vary", "Origin")
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		}
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !api.cors.allowed(origin) {
+			if preflight {
+				writeError(w, r, forbidden("origin not allowed"))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if api.cors.AllowCredentials || !slices.Contains(api.cors.AllowedOrigins, "*") {
+			h.Set("Access-Control-Allow-Origin", origin)
+		} else {
+			h.Set("Access-Control-Allow-Origin", "*")
+		}
+		if api.cors.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			h.Set("Access-Control-Expose-Headers", exposedHeaders)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Set("Access-Control-Allow-Methods", strings.Join(api.cors.AllowedMethods, ", "))
+		if len(api.cors.AllowedHeaders) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(api.cors.AllowedHeaders, ", "))
+		}
+		if api.cors.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(api.cors.MaxAge/time.Second)))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// allowed сообщает, разрешены ли запросы с источника origin.
+func (c *CORS) allowed(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
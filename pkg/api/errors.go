@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"go-news/pkg/storage"
+	"net/http"
+)
+
+// Коды ошибок, возвращаемые в поле error.code.
+const (
+	codeBadRequest           = "bad_request"
+	codeUnauthorized         = "unauthorized"
+	codeForbidden            = "forbidden"
+	codeNotFound             = "not_found"
+	codeConflict             = "conflict"
+	codeInvalid              = "invalid"
+	codeValidation           = "validation_failed"
+	codePayloadTooLarge      = "payload_too_large"
+	codeMethodNotAllowed     = "method_not_allowed"
+	codePreconditionRequired = "precondition_required"
+	codePreconditionFailed   = "precondition_failed"
+	codeRateLimited          = "rate_limited"
+	codeTimeout              = "timeout"
+	codeCanceled             = "canceled"
+	codeInternal             = "internal"
+)
+
+// statusClientClosedRequest - нестандартный статус (как в nginx) для
+// запросов, клиент которых отключился до ответа. Попадает только в логи.
+const statusClientClosedRequest = 499
+
+// ErrorResponse - тело ответа API с ошибкой.
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+// Error - описание ошибки API.
+type Error struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	RequestID string       `json:"request_id,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+
+	status int
+}
+
+// FieldError - ошибка в отдельном поле запроса.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// newError создаёт ошибку API с указанным статусом.
+func newError(status int, code, message string) *Error {
+	return &Error{Code: code, Message: message, status: status}
+}
+
+// badRequest создаёт ошибку 400 с сообщением для клиента.
+func badRequest(message string) *Error {
+	return newError(http.StatusBadRequest, codeBadRequest, message)
+}
+
+// writeError отправляет клиенту ошибку в формате ErrorResponse. Ошибки
+// хранилища переводятся в соответствующие статусы как ошибки задачи, а
+// текст внутренних ошибок в ответ не попадает и только записывается в лог.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	writeResourceError(w, r, "task", err)
+}
+
+// writeResourceError - writeError для обработчиков других ресурсов: ошибки
+// хранилища описываются в ответе как ошибки resource, например "API key".
+func writeResourceError(w http.ResponseWriter, r *http.Request, resource string, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = storageError(err, resource)
+	}
+	body := *e
+	body.RequestID = RequestID(r.Context())
+	if body.status >= http.StatusInternalServerError {
+		loggerFrom(r.Context()).Error("request failed",
+			"method", r.Method, "path", r.URL.Path, "status", body.status, "error", err)
+	}
+	writeJSON(w, r, body.status, ErrorResponse{Error: body})
+}
+
+// ignoreHistoryError записывает в журнал запроса ошибку сохранения истории
+// и возвращает nil: изменение задачи уже применено, а повтор запроса создал
+// бы дубликат задачи или получил 412. Остальные ошибки возвращаются как есть.
+func ignoreHistoryError(r *http.Request, err error) error {
+	if errors.Is(err, storage.ErrHistoryNotRecorded) {
+		loggerFrom(r.Context()).Error("task change not recorded in history",
+			"method", r.Method, "path", r.URL.Path, "error", err)
+		return nil
+	}
+	return err
+}
+
+// storageError переводит ошибку хранилища в ошибку API о ресурсе resource.
+func storageError(err error, resource string) *Error {
+	var vErr *storage.ValidationError
+	if errors.As(err, &vErr) {
+		e := newError(http.StatusUnprocessableEntity, codeValidation, resource+" validation failed")
+		for _, f := range vErr.Fields {
+			e.Fields = append(e.Fields, FieldError{Field: f.Field, Message: f.Message})
+		}
+		return e
+	}
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return newError(http.StatusNotFound, codeNotFound, resource+" not found")
+	case errors.Is(err, storage.ErrConflict):
+		return newError(http.StatusConflict, codeConflict, resource+" conflicts with existing data")
+	case errors.Is(err, storage.ErrInvalid):
+		return newError(http.StatusBadRequest, codeInvalid, resource+" rejected by storage")
+	case errors.Is(err, storage.ErrVersionMismatch):
+		return newError(http.StatusPreconditionFailed, codePreconditionFailed, resource+" was modified by another request")
+	case errors.Is(err, context.DeadlineExceeded):
+		return newError(http.StatusGatewayTimeout, codeTimeout, "request timed out")
+	case errors.Is(err, context.Canceled):
+		return newError(statusClientClosedRequest, codeCanceled, "request canceled")
+	}
+	return newError(http.StatusInternalServerError, codeInternal, "internal server error")
+}
+
+// newRequestID генерирует случайный ID запроса.
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// notFoundHandler отвечает на запросы к неизвестным путям.
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, newError(http.StatusNotFound, codeNotFound, "resource not found"))
+}
+
+// methodNotAllowedHandler отвечает на запросы с неподдерживаемым методом.
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, newError(http.StatusMethodNotAllowed, codeMethodNotAllowed, "method not allowed"))
+}
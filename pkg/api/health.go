@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"go-news/pkg/storage"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout - время, за которое зависимость должна ответить на проверку.
+const readinessTimeout = 2 * time.Second
+
+// Статусы проверок.
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+	statusSkipped     = "skipped"
+)
+
+// HealthResponse - ответ /healthz и /readyz.
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult - результат проверки одной зависимости.
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// healthzHandler сообщает, что процесс жив и обрабатывает запросы.
+func (api *API) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, HealthResponse{Status: statusOK})
+}
+
+// readyzHandler проверяет зависимости сервиса. Если хотя бы одна недоступна,
+// возвращается 503, чтобы балансировщик не направлял сервису запросы.
+func (api *API) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	resp := HealthResponse{
+		Status: statusOK,
+		Checks: map[string]CheckResult{
+			"storage": checkStorage(r.Context(), api.db),
+		},
+	}
+	status := http.StatusOK
+	for _, c := range resp.Checks {
+		if c.Status == statusUnavailable {
+			resp.Status = statusUnavailable
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, r, status, resp)
+}
+
+// checkStorage проверяет хранилище, если оно реализует storage.Pinger.
+// Текст ошибки пишется в лог и в ответ не попадает.
+func checkStorage(ctx context.Context, db storage.Interface) CheckResult {
+	pinger, ok := db.(storage.Pinger)
+	if !ok {
+		return CheckResult{Status: statusSkipped}
+	}
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := pinger.Ping(ctx)
+	res := CheckResult{
+		Status:    statusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		loggerFrom(ctx).Error("readiness check failed", "check", "storage", "error", err)
+		res.Status = statusUnavailable
+		res.Error = "storage is unreachable"
+	}
+	return res
+}
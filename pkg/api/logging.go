@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"go-news/pkg/internal/httpx"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// maxRequestIDLength - максимальная длина ID запроса, принимаемого от клиента.
+const maxRequestIDLength = 128
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	loggerKey
+)
+
+// probePaths - служебные пути, запросы к которым логируются на уровне Debug,
+// чтобы проверки оркестратора не засоряли журнал.
+var probePaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// RequestID возвращает ID запроса, сохранённый в контексте, или пустую строку.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// loggerFrom возвращает логгер запроса с его ID или логгер по умолчанию.
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// requestIDMiddleware берёт ID запроса из заголовка X-Request-ID или
+// генерирует новый, возвращает его в заголовке ответа и сохраняет в контексте
+// вместе с логгером, добавляющим request_id к каждой записи.
+func (api *API) requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := context.WithValue(r.Context(), requestIDKey, id)
+		ctx = context.WithValue(ctx, loggerKey, api.logger.With("request_id", id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// loggingMiddleware пишет в журнал запись о каждом обработанном запросе.
+func (api *API) loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := httpx.NewRecorder(w)
+		next.ServeHTTP(rw, r)
+
+		level := slog.LevelInfo
+		switch {
+		case rw.Status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case rw.Status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case probePaths[r.URL.Path]:
+			level = slog.LevelDebug
+		}
+		loggerFrom(r.Context()).LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rw.Status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int64("bytes", rw.Bytes),
+			slog.String("remote_addr", r.RemoteAddr),
+		)
+	})
+}
+
+// validRequestID проверяет ID запроса от клиента: непустой, ограниченной
+// длины и из печатных ASCII-символов, чтобы его можно было безопасно
+// вернуть в заголовке.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
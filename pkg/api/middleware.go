@@ -0,0 +1,15 @@
+package api
+
+import (
+	"context"
+	"net/http"
+)
+
+// timeoutMiddleware устанавливает крайний срок контекста запроса.
+func (api *API) timeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), api.requestTimeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"go-news/pkg/auth"
+	"go-news/pkg/metrics"
+	"go-news/pkg/ratelimit"
+	"log/slog"
+	"net/netip"
+	"time"
+)
+
+// Option настраивает API при создании.
+type Option func(*API)
+
+// WithRequestTimeout ограничивает время обработки запроса: по истечении d
+// контекст запроса, переданный в хранилище, отменяется. d <= 0 - без ограничения.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(api *API) {
+		api.requestTimeout = d
+	}
+}
+
+// WithMetrics включает сбор метрик HTTP-запросов. Метрики хранилища
+// собираются обёрткой m.Storage, а отдаются m.Handler() на отдельном
+// адресе - и то, и другое создаёт вызывающий.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(api *API) {
+		api.metrics = m
+	}
+}
+
+// WithLogger задаёт логгер для журнала запросов и ошибок. По умолчанию
+// используется slog.Default().
+func WithLogger(l *slog.Logger) Option {
+	return func(api *API) {
+		api.logger = l
+	}
+}
+
+// WithAuth требует для запросов к API JWT, проверяемый v, или ключ API.
+// Без этой опции и WithAPIKeys API доступен без аутентификации.
+func WithAuth(v *auth.Verifier) Option {
+	return func(api *API) {
+		api.auth = v
+		api.authRequired = true
+	}
+}
+
+// WithAPIKeys требует для запросов к API ключ API. Без WithAuth токены
+// JWT не принимаются.
+func WithAPIKeys() Option {
+	return func(api *API) {
+		api.authRequired = true
+	}
+}
+
+// WithRateLimits ограничивает частоту запросов каждого клиента. Ключ limits -
+// ratelimit.Default или "МЕТОД /шаблон" маршрута, например "POST /posts" или
+// "PUT /posts/{id}" (имена и выражения переменных не важны); нулевой лимит
+// маршрута отключает для него ограничение. Ключи проверяет CheckRateLimits.
+func WithRateLimits(limits map[string]ratelimit.Limit) Option {
+	return func(api *API) {
+		api.limiters = make(map[string]*ratelimit.Limiter, len(limits))
+		api.limitRoutes = nil
+		for route, l := range limits {
+			api.limitRoutes = append(api.limitRoutes, route)
+			if l.Unlimited() {
+				api.limiters[routeKey(route)] = nil
+				continue
+			}
+			api.limiters[routeKey(route)] = ratelimit.New(l)
+		}
+	}
+}
+
+// WithTrustedProxies задаёт сети прокси, которым доверяется заголовок
+// X-Forwarded-For при определении IP-адреса клиента.
+func WithTrustedProxies(proxies []netip.Prefix) Option {
+	return func(api *API) {
+		api.trustedProxies = proxies
+	}
+}
+
+// WithCORS разрешает запросы из браузера со страниц источников c.AllowedOrigins.
+// Без этой опции заголовки CORS не добавляются.
+func WithCORS(c CORS) Option {
+	return func(api *API) {
+		api.cors = &c
+	}
+}
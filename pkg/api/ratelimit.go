@@ -0,0 +1,171 @@
+package api
+
+import (
+	"fmt"
+	"go-news/pkg/auth"
+	"go-news/pkg/ratelimit"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// rateLimitMiddleware ограничивает частоту запросов каждого клиента лимитом
+// маршрута или лимитом ratelimit.Default. Пути из publicPaths не
+// ограничиваются.
+func (api *API) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if api.allow(w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// allow расходует токен клиента запроса и добавляет в ответ заголовки
+// RateLimit-*. Если лимит исчерпан, allow отвечает 429 с Retry-After и
+// возвращает false.
+func (api *API) allow(w http.ResponseWriter, r *http.Request) bool {
+	limiter := api.limiter(r)
+	if limiter == nil || publicPaths[r.URL.Path] {
+		return true
+	}
+	res := limiter.Allow(api.clientKey(r))
+	l := limiter.Limit()
+	h := w.Header()
+	h.Set("RateLimit-Policy", strconv.Itoa(l.Requests)+";w="+strconv.Itoa(ceilSeconds(l.Period)))
+	h.Set("RateLimit-Limit", strconv.Itoa(l.Requests))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+		writeError(w, r, newError(http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded"))
+		return false
+	}
+	return true
+}
+
+// limiter возвращает ограничитель маршрута запроса или nil, если лимит
+// маршрута отключён.
+func (api *API) limiter(r *http.Request) *ratelimit.Limiter {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			if l, ok := api.limiters[routeKey(r.Method+" "+tpl)]; ok {
+				return l
+			}
+		}
+	}
+	return api.limiters[ratelimit.Default]
+}
+
+// CheckRateLimits проверяет, что каждый лимит из WithRateLimits, кроме
+// ratelimit.Default, относится к зарегистрированному маршруту: лимит с
+// опечаткой в методе или пути иначе молча не действовал бы.
+func (api *API) CheckRateLimits() error {
+	routes := make(map[string]bool)
+	err := api.router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			routes[routeKey(method+" "+tpl)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var unknown []string
+	for _, route := range api.limitRoutes {
+		if route != ratelimit.Default && !routes[routeKey(route)] {
+			unknown = append(unknown, strconv.Quote(route))
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return fmt.Errorf("rate limits for unknown routes %s: want \"METHOD /path\" of a route, e.g. \"PUT /posts/{id}\"",
+			strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// routeKey приводит "МЕТОД /шаблон" к виду, в котором переменные шаблона
+// без имён и регулярных выражений: "PUT /posts/{id:[0-9]+}" и
+// "PUT /posts/{id}" дают один ключ "PUT /posts/{}".
+func routeKey(route string) string {
+	var b strings.Builder
+	depth := 0
+	for _, c := range route {
+		switch {
+		case c == '{':
+			if depth == 0 {
+				b.WriteRune(c)
+			}
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+			if depth == 0 {
+				b.WriteRune(c)
+			}
+		case depth == 0:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// clientKey возвращает ключ корзины клиента: аутентифицированные клиенты
+// (пользователи и ключи API) различаются по subject, остальные - по IP.
+func (api *API) clientKey(r *http.Request) string {
+	if id, ok := auth.FromContext(r.Context()); ok {
+		return "sub:" + id.Subject
+	}
+	return "ip:" + api.clientIP(r)
+}
+
+// clientIP возвращает адрес клиента. X-Forwarded-For учитывается, только
+// если запрос пришёл от доверенного прокси: адреса в нём просматриваются
+// справа налево до первого недоверенного, подделанные клиентом адреса левее
+// него игнорируются.
+func (api *API) clientIP(r *http.Request) string {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	ip := addrPort.Addr().Unmap()
+	if !api.trustedProxy(ip) {
+		return ip.String()
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		ip = hop.Unmap()
+		if !api.trustedProxy(ip) {
+			break
+		}
+	}
+	return ip.String()
+}
+
+// trustedProxy сообщает, что ip принадлежит доверенному прокси.
+func (api *API) trustedProxy(ip netip.Addr) bool {
+	for _, p := range api.trustedProxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ceilSeconds округляет d вверх до целых секунд.
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
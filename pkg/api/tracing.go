@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"go-news/pkg/internal/httpx"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("go-news/pkg/api")
+
+// attributeRequestID - атрибут span с ID запроса для поиска трассировки по журналу.
+const attributeRequestID = attribute.Key("request_id")
+
+// tracingMiddleware начинает серверный span запроса, продолжая трассировку
+// из заголовка traceparent, если клиент его передал. ID трассировки
+// добавляется в журнал запроса.
+func (api *API) tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				attributeRequestID.String(RequestID(ctx)),
+			),
+		)
+		defer span.End()
+		if sc := span.SpanContext(); sc.IsValid() {
+			ctx = context.WithValue(ctx, loggerKey, loggerFrom(ctx).With("trace_id", sc.TraceID().String()))
+		}
+
+		rw := httpx.NewRecorder(w)
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rw.Status))
+		if rw.Status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rw.Status))
+		}
+	})
+}
+
+// spanRouteMiddleware называет span запроса по шаблону маршрута mux, который
+// известен только после сопоставления, поэтому подключается через Router.Use.
+func spanRouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if tpl, err := route.GetPathTemplate(); err == nil {
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + tpl)
+				span.SetAttributes(semconv.HTTPRoute(tpl))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// apiKeyPrefix отличает ключи API от JWT и упрощает поиск утёкших ключей.
+const apiKeyPrefix = "gn_"
+
+// NewAPIKey генерирует ключ API и возвращает его вместе с хешем для хранения.
+// Ключ показывается клиенту один раз и нигде не сохраняется.
+func NewAPIKey() (key, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey возвращает SHA-256 ключа в hex. Ключи случайные и длинные,
+// поэтому медленный хеш вроде bcrypt не нужен и поиск идёт по равенству хеша.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(key)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Алгоритмы подписи токенов.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+)
+
+// leeway - допустимое расхождение часов при проверке exp, nbf и iat.
+const leeway = 30 * time.Second
+
+// ErrNoKeys - не задан ни секрет HS256, ни ключи RS256.
+var ErrNoKeys = errors.New("no token verification keys configured")
+
+// Роли вызывающего (claim roles).
+const (
+	// RoleAdmin может всё, в том числе удалять и восстанавливать задачи.
+	RoleAdmin = "admin"
+	// RoleManager может создавать, изменять и переназначать любые задачи.
+	RoleManager = "manager"
+	// RoleAssignee может изменять только свои задачи, не переназначая их.
+	RoleAssignee = "assignee"
+)
+
+// Identity - аутентифицированный вызывающий.
+type Identity struct {
+	// Subject - идентификатор пользователя или сервиса (claim sub).
+	Subject string
+	// UserID - ID пользователя, с которым сравнивается Task.ResponsibleID:
+	// claim user_id или sub, если он число; 0 - не задан.
+	UserID int
+	// Roles - роли вызывающего (claim roles).
+	Roles []string
+}
+
+// HasRole сообщает, есть ли у вызывающего хотя бы одна из ролей roles.
+func (id Identity) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(id.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// claims - claims токена, из которых строится Identity.
+type claims struct {
+	jwt.RegisteredClaims
+	UserID int      `json:"user_id"`
+	Roles  []string `json:"roles"`
+}
+
+type identityKey struct{}
+
+// WithIdentity возвращает контекст с личностью вызывающего.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext возвращает личность вызывающего, если запрос аутентифицирован.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// Verifier проверяет подпись и срок действия JWT: HS256 с общим секретом
+// и RS256 с ключами из локального JWKS-файла.
+type Verifier struct {
+	secret   []byte
+	keys     map[string]any // RS256: kid -> *rsa.PublicKey
+	issuer   string
+	audience string
+}
+
+// Option настраивает Verifier при создании.
+type Option func(*Verifier) error
+
+// WithSecret разрешает токены HS256, подписанные secret.
+func WithSecret(secret []byte) Option {
+	return func(v *Verifier) error {
+		v.secret = secret
+		return nil
+	}
+}
+
+// WithJWKSFile разрешает токены RS256, подписанные ключами из JWKS-файла path.
+func WithJWKSFile(path string) Option {
+	return func(v *Verifier) error {
+		keys, err := LoadJWKS(path)
+		if err != nil {
+			return err
+		}
+		v.keys = keys
+		return nil
+	}
+}
+
+// WithIssuer требует, чтобы claim iss токена был равен issuer.
+func WithIssuer(issuer string) Option {
+	return func(v *Verifier) error {
+		v.issuer = issuer
+		return nil
+	}
+}
+
+// WithAudience требует, чтобы claim aud токена содержал audience.
+func WithAudience(audience string) Option {
+	return func(v *Verifier) error {
+		v.audience = audience
+		return nil
+	}
+}
+
+// New создаёт Verifier. Должен быть задан хотя бы один способ проверки
+// подписи: WithSecret или WithJWKSFile.
+func New(opts ...Option) (*Verifier, error) {
+	var v Verifier
+	for _, opt := range opts {
+		if err := opt(&v); err != nil {
+			return nil, err
+		}
+	}
+	if len(v.secret) == 0 && len(v.keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	return &v, nil
+}
+
+// Verify проверяет токен и возвращает личность вызывающего. Токен должен
+// содержать exp и непустой sub.
+func (v *Verifier) Verify(token string) (Identity, error) {
+	var methods []string
+	if len(v.secret) > 0 {
+		methods = append(methods, AlgHS256)
+	}
+	if len(v.keys) > 0 {
+		methods = append(methods, AlgRS256)
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if v.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(v.issuer))
+	}
+	if v.audience != "" {
+		opts = append(opts, jwt.WithAudience(v.audience))
+	}
+
+	var c claims
+	if _, err := jwt.ParseWithClaims(token, &c, v.key, opts...); err != nil {
+		return Identity{}, err
+	}
+	if c.Subject == "" {
+		return Identity{}, errors.New("token has no subject")
+	}
+	id := Identity{Subject: c.Subject, UserID: c.UserID, Roles: c.Roles}
+	if id.UserID == 0 {
+		id.UserID, _ = strconv.Atoi(c.Subject)
+	}
+	return id, nil
+}
+
+// key выбирает ключ проверки подписи по алгоритму и kid токена. Алгоритм
+// уже проверен парсером по списку WithValidMethods.
+func (v *Verifier) key(t *jwt.Token) (any, error) {
+	if t.Method.Alg() == AlgHS256 {
+		return v.secret, nil
+	}
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" && len(v.keys) == 1 {
+		// Токен без kid допустим, если ключ единственный.
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// jwk - открытый ключ в формате JSON Web Key (RFC 7517). Поддерживаются
+// только ключи RSA.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// LoadJWKS читает из файла path набор ключей {"keys": [...]} и возвращает
+// открытые ключи RSA по kid. Ключи других типов, а также ключи для
+// шифрования (use "enc") и других алгоритмов пропускаются.
+func LoadJWKS(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwks %s: %w", path, err)
+	}
+	keys := make(map[string]any)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != AlgRS256) {
+			continue
+		}
+		if _, ok := keys[k.Kid]; ok {
+			return nil, fmt.Errorf("jwks %s: duplicate key id %q", path, k.Kid)
+		}
+		key, err := k.rsaPublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks %s: key %q: %w", path, k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks %s: no RSA signing keys", path)
+	}
+	return keys, nil
+}
+
+// rsaPublicKey декодирует модуль n и экспоненту e ключа.
+func (k jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("exponent: %w", err)
+	}
+	exp := new(big.Int).SetBytes(e)
+	if len(n) < 2048/8 {
+		return nil, errors.New("modulus is shorter than 2048 bits")
+	}
+	if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+}
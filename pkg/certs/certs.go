@@ -0,0 +1,155 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Проверка сертификатов клиентов (mTLS), если задан CA клиентов.
+const (
+	// ClientAuthRequire - без сертификата, подписанного CA клиентов,
+	// соединение отклоняется.
+	ClientAuthRequire = "require"
+	// ClientAuthOptional - сертификат необязателен, но переданный должен
+	// быть подписан CA клиентов.
+	ClientAuthOptional = "optional"
+)
+
+// Reloader хранит сертификат сервера и CA клиентов, загруженные из файлов,
+// и перечитывает их при изменении файлов (см. Reload), не прерывая работу
+// сервера.
+type Reloader struct {
+	certFile, keyFile, clientCAFile string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	stamps   []stamp
+}
+
+// stamp - время изменения и размер файла при последней загрузке.
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader загружает сертификат и ключ сервера и, если clientCAFile не
+// пуст, сертификаты CA, которым должны быть подписаны сертификаты клиентов.
+func NewReloader(certFile, keyFile, clientCAFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload перечитывает файлы, если хотя бы один из них изменился с прошлой
+// загрузки, и сообщает, были ли они перечитаны. При ошибке продолжают
+// использоваться загруженные ранее сертификаты.
+func (r *Reloader) Reload() (bool, error) {
+	stamps, err := r.stat()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	unchanged := slices.Equal(stamps, r.stamps)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("tls certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return false, fmt.Errorf("tls client CA: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return false, fmt.Errorf("tls client CA %s: no PEM certificates", r.clientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.clientCA, r.stamps = &cert, pool, stamps
+	r.mu.Unlock()
+	return true, nil
+}
+
+// stat возвращает отметки всех файлов.
+func (r *Reloader) stat() ([]stamp, error) {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+	stamps := make([]stamp, 0, len(files))
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		stamps = append(stamps, stamp{modTime: fi.ModTime(), size: fi.Size()})
+	}
+	return stamps, nil
+}
+
+// TLSConfig возвращает настройки TLS сервера, всегда использующие текущие
+// сертификаты. clientAuth (ClientAuthRequire или ClientAuthOptional)
+// учитывается, только если задан CA клиентов.
+func (r *Reloader) TLSConfig(clientAuth string) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.cert, nil
+		},
+	}
+	if r.clientCAFile == "" {
+		return cfg
+	}
+	// Цепочка клиента проверяется в VerifyConnection, а не через ClientCAs:
+	// так обновлённый CA применяется к новым соединениям без пересоздания
+	// tls.Config.
+	cfg.ClientAuth = tls.RequireAnyClientCert
+	if clientAuth == ClientAuthOptional {
+		cfg.ClientAuth = tls.RequestClientCert
+	}
+	cfg.VerifyConnection = r.verifyClient
+	return cfg
+}
+
+// verifyClient проверяет, что сертификат клиента, если он передан, подписан
+// CA клиентов и предназначен для аутентификации клиента.
+func (r *Reloader) verifyClient(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		// Обязательность сертификата уже проверена по ClientAuth.
+		return nil
+	}
+	r.mu.RLock()
+	pool := r.clientCA
+	r.mu.RUnlock()
+
+	intermediates := x509.NewCertPool()
+	for _, c := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return fmt.Errorf("tls: client certificate rejected: %w", err)
+	}
+	return nil
+}
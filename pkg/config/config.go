@@ -1,76 +1,463 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"strings"
+	"time"
+
+	"go-news/pkg/certs"
+	"go-news/pkg/ratelimit"
+	"go-news/pkg/tracing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Поддерживаемые драйверы хранилища
+const (
+	DriverPostgres = "postgres"
+	DriverMongo    = "mongo"
+	DriverMemory   = "memory"
 )
 
-// Config хранит конфигурацию приложения
+// minJWTSecretLength - минимальная длина секрета HS256 (RFC 7518, 3.2).
+const minJWTSecretLength = 32
+
+// Config хранит конфигурацию приложения. Значения берутся по умолчанию,
+// затем из файла конфигурации (если он указан), затем из переменных
+// окружения. Для каждой переменной X можно указать X_FILE - путь к файлу
+// со значением (так монтируются Docker secrets).
 type Config struct {
-	// Настройки базы данных
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
+	// Драйвер хранилища: postgres, mongo или memory
+	StorageDriver string `yaml:"storage_driver"`
+
+	// Настройки базы данных PostgreSQL
+	DBHost     string `yaml:"db_host"`
+	DBPort     string `yaml:"db_port"`
+	DBUser     string `yaml:"db_user"`
+	DBPassword string `yaml:"db_password"`
+	DBName     string `yaml:"db_name"`
+
+	// Строка подключения к MongoDB
+	MongoURI string `yaml:"mongo_uri"`
+
+	// Применять недостающие миграции схемы хранилища при запуске сервера
+	AutoMigrate bool `yaml:"auto_migrate"`
 
 	// Настройки приложения
-	AppPort string
-	AppEnv  string
+	AppPort string `yaml:"app_port"`
+	AppEnv  string `yaml:"app_env"`
+	// Адрес отдельного HTTP-сервера с /metrics для Prometheus (пустой -
+	// метрики не отдаются). API метрики не отдаёт: они доступны без
+	// аутентификации, поэтому адрес не должен быть виден снаружи.
+	MetricsAddr string `yaml:"metrics_addr"`
+
+	// Таймауты HTTP-сервера и время на завершение текущих запросов при остановке
+	HTTPReadTimeout       time.Duration `yaml:"http_read_timeout"`
+	HTTPReadHeaderTimeout time.Duration `yaml:"http_read_header_timeout"`
+	HTTPWriteTimeout      time.Duration `yaml:"http_write_timeout"`
+	HTTPIdleTimeout       time.Duration `yaml:"http_idle_timeout"`
+	ShutdownTimeout       time.Duration `yaml:"shutdown_timeout"`
+
+	// Крайний срок обработки одного запроса, включая обращения к хранилищу
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+
+	// Экспортёр трассировки: none, stdout или otlp, и адрес OTLP-коллектора:
+	// host:port или https://host:port - по TLS, http://host:port - без
+	// шифрования (пустой - из OTEL_EXPORTER_OTLP_ENDPOINT)
+	TracingExporter string `yaml:"tracing_exporter"`
+	OTLPEndpoint    string `yaml:"otlp_endpoint"`
+
+	// Сколько дней удалённые задачи хранятся в корзине (0 - не удалять
+	// окончательно) и как часто запускается их очистка
+	TrashRetentionDays int           `yaml:"trash_retention_days"`
+	PurgeInterval      time.Duration `yaml:"purge_interval"`
+
+	// Аутентификация JWT: секрет HS256 и/или путь к JWKS-файлу с ключами
+	// RS256. Если не задано ни то, ни другое, API доступен без токена.
+	// Необязательные JWTIssuer и JWTAudience проверяются в claims iss и aud.
+	JWTSecret   string `yaml:"jwt_secret"`
+	JWKSFile    string `yaml:"jwks_file"`
+	JWTIssuer   string `yaml:"jwt_issuer"`
+	JWTAudience string `yaml:"jwt_audience"`
+	// Требовать ключ API, даже если JWT не настроен. С JWT ключи API
+	// принимаются всегда.
+	APIKeysEnabled bool `yaml:"api_keys_enabled"`
+
+	// Лимиты запросов одного клиента: "default" - для всех маршрутов без
+	// своего лимита, "МЕТОД /шаблон" - для маршрута, например
+	// "PUT /posts/{id}". Значение - "60/1m" (запросов за период) или "off".
+	// Существование маршрутов проверяет сервер при запуске.
+	RateLimits map[string]string `yaml:"rate_limits"`
+	// Адреса и сети прокси (nginx), которым доверяется X-Forwarded-For
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// CORS для браузерных клиентов: источники вида https://app.example.com
+	// ("*" - любой; пустой список отключает CORS), разрешённые методы и
+	// заголовки, передача cookie и Authorization, время кеширования
+	// preflight-ответа браузером
+	CORSAllowedOrigins   []string      `yaml:"cors_allowed_origins"`
+	CORSAllowedMethods   []string      `yaml:"cors_allowed_methods"`
+	CORSAllowedHeaders   []string      `yaml:"cors_allowed_headers"`
+	CORSAllowCredentials bool          `yaml:"cors_allow_credentials"`
+	CORSMaxAge           time.Duration `yaml:"cors_max_age"`
+
+	// HTTPS без nginx: сертификат и ключ сервера (без них - HTTP). Если
+	// задан CA клиентов, клиенты предъявляют подписанный им сертификат:
+	// всегда (require) или по желанию (optional). Файлы проверяются на
+	// изменения раз в TLSReloadInterval и перечитываются без перезапуска.
+	TLSCertFile       string        `yaml:"tls_cert_file"`
+	TLSKeyFile        string        `yaml:"tls_key_file"`
+	TLSClientCAFile   string        `yaml:"tls_client_ca_file"`
+	TLSClientAuth     string        `yaml:"tls_client_auth"`
+	TLSReloadInterval time.Duration `yaml:"tls_reload_interval"`
+
+	// loadErr - ошибка чтения переменных окружения в Load, её возвращает Validate
+	loadErr error
 }
 
 // Load загружает конфигурацию из переменных окружения
 func Load() *Config {
-	cfg := &Config{
+	cfg := defaults()
+	cfg.loadErr = cfg.applyEnv()
+	return cfg
+}
+
+// LoadFile загружает конфигурацию из YAML-файла path и переопределяет её
+// значения переменными окружения. Если path пуст, файл не читается.
+func LoadFile(path string) (*Config, error) {
+	cfg := defaults()
+	if path != "" {
+		if err := cfg.applyFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// defaults возвращает конфигурацию со значениями по умолчанию
+func defaults() *Config {
+	return &Config{
+		StorageDriver: DriverPostgres,
+
 		// База данных
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBUser:     getEnv("DB_USER", "postgres"),
-		DBPassword: getEnv("DB_PASSWORD", "postgres"),
-		DBName:     getEnv("DB_NAME", "news_db"),
+		DBHost:     "localhost",
+		DBPort:     "5432",
+		DBUser:     "postgres",
+		DBPassword: "postgres",
+		DBName:     "news_db",
+
+		// MongoDB
+		MongoURI: "mongodb://localhost:27017",
+
+		AutoMigrate: true,
 
 		// Приложение
-		AppPort: getEnv("APP_PORT", "8080"),
-		AppEnv:  getEnv("APP_ENV", "development"),
-	}
+		AppPort:     "8080",
+		AppEnv:      "development",
+		MetricsAddr: ":9090",
 
-	return cfg
+		// HTTP-сервер
+		HTTPReadTimeout:       15 * time.Second,
+		HTTPReadHeaderTimeout: 5 * time.Second,
+		HTTPWriteTimeout:      30 * time.Second,
+		HTTPIdleTimeout:       60 * time.Second,
+		ShutdownTimeout:       20 * time.Second,
+		RequestTimeout:        10 * time.Second,
+
+		// Трассировка
+		TracingExporter: tracing.ExporterNone,
+
+		// Корзина
+		TrashRetentionDays: 30,
+		PurgeInterval:      time.Hour,
+
+		// Лимиты запросов
+		RateLimits: map[string]string{
+			ratelimit.Default: "600/1m",
+			"POST /posts":     "60/1m",
+		},
+
+		// CORS
+		CORSAllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		CORSAllowedHeaders: []string{"Authorization", "Content-Type", "If-Match", "X-API-Key", "X-Request-ID"},
+		CORSMaxAge:         10 * time.Minute,
+
+		// TLS
+		TLSClientAuth:     certs.ClientAuthRequire,
+		TLSReloadInterval: 30 * time.Second,
+	}
 }
 
-// getEnv получает значение переменной окружения или значение по умолчанию
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// applyFile читает значения из YAML-файла. Неизвестные ключи считаются ошибкой.
+func (c *Config) applyFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
 	}
-	return defaultValue
+	return nil
+}
+
+// applyEnv переопределяет значения переменными окружения
+func (c *Config) applyEnv() error {
+	var env envReader
+	env.string(&c.StorageDriver, "STORAGE_DRIVER")
+
+	env.string(&c.DBHost, "DB_HOST")
+	env.string(&c.DBPort, "DB_PORT")
+	env.string(&c.DBUser, "DB_USER")
+	env.string(&c.DBPassword, "DB_PASSWORD")
+	env.string(&c.DBName, "DB_NAME")
+
+	env.string(&c.MongoURI, "MONGO_URI")
+	env.bool(&c.AutoMigrate, "AUTO_MIGRATE")
+
+	env.string(&c.AppPort, "APP_PORT")
+	env.string(&c.AppEnv, "APP_ENV")
+	env.string(&c.MetricsAddr, "METRICS_ADDR")
+
+	env.duration(&c.HTTPReadTimeout, "HTTP_READ_TIMEOUT")
+	env.duration(&c.HTTPReadHeaderTimeout, "HTTP_READ_HEADER_TIMEOUT")
+	env.duration(&c.HTTPWriteTimeout, "HTTP_WRITE_TIMEOUT")
+	env.duration(&c.HTTPIdleTimeout, "HTTP_IDLE_TIMEOUT")
+	env.duration(&c.ShutdownTimeout, "SHUTDOWN_TIMEOUT")
+	env.duration(&c.RequestTimeout, "REQUEST_TIMEOUT")
+
+	env.string(&c.TracingExporter, "TRACING_EXPORTER")
+	env.string(&c.OTLPEndpoint, "OTLP_ENDPOINT")
+
+	env.int(&c.TrashRetentionDays, "TRASH_RETENTION_DAYS")
+	env.duration(&c.PurgeInterval, "PURGE_INTERVAL")
+
+	env.string(&c.JWTSecret, "JWT_SECRET")
+	env.string(&c.JWKSFile, "JWKS_FILE")
+	env.string(&c.JWTIssuer, "JWT_ISSUER")
+	env.string(&c.JWTAudience, "JWT_AUDIENCE")
+	env.bool(&c.APIKeysEnabled, "API_KEYS_ENABLED")
+
+	env.stringMap(&c.RateLimits, "RATE_LIMITS")
+	env.list(&c.TrustedProxies, "TRUSTED_PROXIES")
+
+	env.list(&c.CORSAllowedOrigins, "CORS_ALLOWED_ORIGINS")
+	env.list(&c.CORSAllowedMethods, "CORS_ALLOWED_METHODS")
+	env.list(&c.CORSAllowedHeaders, "CORS_ALLOWED_HEADERS")
+	env.bool(&c.CORSAllowCredentials, "CORS_ALLOW_CREDENTIALS")
+	env.duration(&c.CORSMaxAge, "CORS_MAX_AGE")
+
+	env.string(&c.TLSCertFile, "TLS_CERT_FILE")
+	env.string(&c.TLSKeyFile, "TLS_KEY_FILE")
+	env.string(&c.TLSClientCAFile, "TLS_CLIENT_CA_FILE")
+	env.string(&c.TLSClientAuth, "TLS_CLIENT_AUTH")
+	env.duration(&c.TLSReloadInterval, "TLS_RELOAD_INTERVAL")
+	return env.err()
 }
 
 // GetDSN возвращает строку подключения к PostgreSQL
 func (c *Config) GetDSN() string {
-	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		c.DBUser, c.DBPassword, c.DBHost, c.DBPort, c.DBName)
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.DBUser, c.DBPassword),
+		Host:     net.JoinHostPort(c.DBHost, c.DBPort),
+		Path:     "/" + c.DBName,
+		RawQuery: "sslmode=disable",
+	}
+	return u.String()
 }
 
 // Validate проверяет корректность конфигурации
 func (c *Config) Validate() error {
-	if c.DBHost == "" {
-		return fmt.Errorf("DB_HOST is required")
-	}
-	if c.DBPort == "" {
-		return fmt.Errorf("DB_PORT is required")
+	if c.loadErr != nil {
+		return c.loadErr
 	}
-	if c.DBUser == "" {
-		return fmt.Errorf("DB_USER is required")
-	}
-	if c.DBName == "" {
-		return fmt.Errorf("DB_NAME is required")
+	switch c.StorageDriver {
+	case DriverPostgres:
+		if c.DBHost == "" {
+			return fmt.Errorf("DB_HOST is required")
+		}
+		if c.DBPort == "" {
+			return fmt.Errorf("DB_PORT is required")
+		}
+		if c.DBUser == "" {
+			return fmt.Errorf("DB_USER is required")
+		}
+		if c.DBName == "" {
+			return fmt.Errorf("DB_NAME is required")
+		}
+	case DriverMongo:
+		if c.MongoURI == "" {
+			return fmt.Errorf("MONGO_URI is required")
+		}
+	case DriverMemory:
+	default:
+		return fmt.Errorf("unknown STORAGE_DRIVER %q: must be %s, %s or %s",
+			c.StorageDriver, DriverPostgres, DriverMongo, DriverMemory)
 	}
 	if c.AppPort == "" {
 		return fmt.Errorf("APP_PORT is required")
 	}
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			return fmt.Errorf("METRICS_ADDR: %w", err)
+		}
+	}
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"HTTP_READ_TIMEOUT", c.HTTPReadTimeout},
+		{"HTTP_READ_HEADER_TIMEOUT", c.HTTPReadHeaderTimeout},
+		{"HTTP_WRITE_TIMEOUT", c.HTTPWriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", c.HTTPIdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+		{"REQUEST_TIMEOUT", c.RequestTimeout},
+		{"PURGE_INTERVAL", c.PurgeInterval},
+		{"TLS_RELOAD_INTERVAL", c.TLSReloadInterval},
+	}
+	for _, t := range timeouts {
+		if t.value <= 0 {
+			return fmt.Errorf("%s must be positive", t.name)
+		}
+	}
+	if c.TrashRetentionDays < 0 {
+		return fmt.Errorf("TRASH_RETENTION_DAYS must not be negative")
+	}
+	if c.JWTSecret != "" && len(c.JWTSecret) < minJWTSecretLength {
+		return fmt.Errorf("JWT_SECRET must be at least %d bytes", minJWTSecretLength)
+	}
+	if _, err := c.Limits(); err != nil {
+		return err
+	}
+	if _, err := c.Proxies(); err != nil {
+		return err
+	}
+	if err := c.validateCORS(); err != nil {
+		return err
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.TLSClientCAFile != "" && !c.TLSEnabled() {
+		return fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	switch c.TLSClientAuth {
+	case certs.ClientAuthRequire, certs.ClientAuthOptional:
+	default:
+		return fmt.Errorf("unknown TLS_CLIENT_AUTH %q: must be %s or %s",
+			c.TLSClientAuth, certs.ClientAuthRequire, certs.ClientAuthOptional)
+	}
+	if err := tracing.CheckEndpoint(c.OTLPEndpoint); err != nil {
+		return fmt.Errorf("OTLP_ENDPOINT: %w", err)
+	}
+	switch c.TracingExporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		return fmt.Errorf("unknown TRACING_EXPORTER %q: must be %s, %s or %s",
+			c.TracingExporter, tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP)
+	}
+	return nil
+}
+
+// AuthEnabled сообщает, требуется ли аутентификация: JWT или ключом API.
+func (c *Config) AuthEnabled() bool {
+	return c.JWTEnabled() || c.APIKeysEnabled
+}
+
+// JWTEnabled сообщает, заданы ли ключи проверки JWT.
+func (c *Config) JWTEnabled() bool {
+	return c.JWTSecret != "" || c.JWKSFile != ""
+}
+
+// TLSEnabled сообщает, обслуживает ли сервер HTTPS сам.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != ""
+}
+
+// validateCORS проверяет настройки CORS.
+func (c *Config) validateCORS() error {
+	for _, origin := range c.CORSAllowedOrigins {
+		if origin == "*" {
+			if c.CORSAllowCredentials {
+				return fmt.Errorf("CORS_ALLOWED_ORIGINS: \"*\" cannot be used with CORS_ALLOW_CREDENTIALS")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.Path != "" || u.RawQuery != "" || u.User != nil {
+			return fmt.Errorf("CORS_ALLOWED_ORIGINS: %q must be \"*\" or scheme://host[:port]", origin)
+		}
+	}
+	for _, method := range c.CORSAllowedMethods {
+		if method == "" || method != strings.ToUpper(method) {
+			return fmt.Errorf("CORS_ALLOWED_METHODS: invalid method %q", method)
+		}
+	}
+	if c.CORSMaxAge < 0 {
+		return fmt.Errorf("CORS_MAX_AGE must not be negative")
+	}
 	return nil
 }
 
+// Limits возвращает разобранные лимиты запросов RateLimits.
+func (c *Config) Limits() (map[string]ratelimit.Limit, error) {
+	limits := make(map[string]ratelimit.Limit, len(c.RateLimits))
+	for route, value := range c.RateLimits {
+		method, path, _ := strings.Cut(route, " ")
+		if route != ratelimit.Default && (method == "" || method != strings.ToUpper(method) || !strings.HasPrefix(path, "/")) {
+			return nil, fmt.Errorf("RATE_LIMITS: route %q must be %q or \"METHOD /path\"", route, ratelimit.Default)
+		}
+		l, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("RATE_LIMITS: %w", err)
+		}
+		limits[route] = l
+	}
+	return limits, nil
+}
+
+// Proxies возвращает сети доверенных прокси TrustedProxies; отдельный
+// адрес - сеть из одного адреса.
+func (c *Config) Proxies() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, s := range c.TrustedProxies {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(s)
+			if addrErr != nil {
+				return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// LogLevel возвращает уровень журналирования для окружения AppEnv:
+// Debug для development и test, Info для остальных.
+func (c *Config) LogLevel() slog.Level {
+	switch c.AppEnv {
+	case "development", "test":
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
 // LogMask возвращает конфигурацию с замаскированным паролем для логирования
 func (c *Config) LogMask() string {
 	maskedPassword := "***"
@@ -78,7 +465,16 @@ func (c *Config) LogMask() string {
 		maskedPassword = "***"
 	}
 	return fmt.Sprintf(
-		"Config{DBHost: %s, DBPort: %s, DBUser: %s, DBPassword: %s, DBName: %s, AppPort: %s, AppEnv: %s}",
-		c.DBHost, c.DBPort, c.DBUser, maskedPassword, c.DBName, c.AppPort, c.AppEnv,
+		"Config{StorageDriver: %s, DBHost: %s, DBPort: %s, DBUser: %s, DBPassword: %s, DBName: %s, MongoURI: %s, AppPort: %s, AppEnv: %s}",
+		c.StorageDriver, c.DBHost, c.DBPort, c.DBUser, maskedPassword, c.DBName, maskURI(c.MongoURI), c.AppPort, c.AppEnv,
 	)
 }
+
+// maskURI заменяет пароль в строке подключения на "xxxxx"
+func maskURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "***"
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envReader читает переменные окружения и накапливает ошибки чтения
+type envReader struct {
+	errs []error
+}
+
+// lookup возвращает значение переменной key или содержимое файла из
+// key_FILE. Завершающий перевод строки в файле отбрасывается.
+func (r *envReader) lookup(key string) (string, bool) {
+	value, ok := os.LookupEnv(key)
+	path, fromFile := os.LookupEnv(key + "_FILE")
+	switch {
+	case ok && value != "" && fromFile:
+		r.errs = append(r.errs, fmt.Errorf("both %s and %s_FILE are set", key, key))
+		return "", false
+	case fromFile:
+		b, err := os.ReadFile(path)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s_FILE: %w", key, err))
+			return "", false
+		}
+		return strings.TrimRight(string(b), "\r\n"), true
+	}
+	return value, value != ""
+}
+
+// string записывает в dst значение переменной key, если она задана
+func (r *envReader) string(dst *string, key string) {
+	if value, ok := r.lookup(key); ok {
+		*dst = value
+	}
+}
+
+// duration записывает в dst длительность из переменной key ("5s", "1m30s")
+func (r *envReader) duration(dst *time.Duration, key string) {
+	value, ok := r.lookup(key)
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s: %w", key, err))
+		return
+	}
+	*dst = d
+}
+
+// bool записывает в dst логическое значение переменной key ("true", "false", "1", "0")
+func (r *envReader) bool(dst *bool, key string) {
+	value, ok := r.lookup(key)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s: %w", key, err))
+		return
+	}
+	*dst = b
+}
+
+// int записывает в dst целое число из переменной key
+func (r *envReader) int(dst *int, key string) {
+	value, ok := r.lookup(key)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s: %w", key, err))
+		return
+	}
+	*dst = n
+}
+
+// list записывает в dst список значений через запятую из переменной key
+func (r *envReader) list(dst *[]string, key string) {
+	value, ok := r.lookup(key)
+	if !ok {
+		return
+	}
+	*dst = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*dst = append(*dst, item)
+		}
+	}
+}
+
+// stringMap добавляет в dst пары "ключ=значение" через запятую из переменной
+// key, заменяя значения существующих ключей
+func (r *envReader) stringMap(dst *map[string]string, key string) {
+	value, ok := r.lookup(key)
+	if !ok {
+		return
+	}
+	if *dst == nil {
+		*dst = make(map[string]string)
+	}
+	for _, item := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(item, "=")
+		if !ok {
+			r.errs = append(r.errs, fmt.Errorf("%s: %q is not key=value", key, item))
+			continue
+		}
+		(*dst)[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+}
+
+func (r *envReader) err() error {
+	return errors.Join(r.errs...)
+}
@@ -0,0 +1,41 @@
+// Package httpx содержит вспомогательные типы HTTP, общие для middleware
+// API и метрик.
+package httpx
+
+import "net/http"
+
+// Recorder запоминает код ответа и число отправленных байт.
+type Recorder struct {
+	http.ResponseWriter
+	// Status - код ответа, http.StatusOK, если обработчик его не задал.
+	Status int
+	// Bytes - число байт тела ответа, записанных обработчиком.
+	Bytes int64
+
+	wroteHeader bool
+}
+
+// NewRecorder оборачивает w.
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (w *Recorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.Status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *Recorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.Bytes += int64(n)
+	return n, err
+}
+
+// Unwrap открывает доступ к исходному ResponseWriter для http.ResponseController.
+func (w *Recorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,94 @@
+// Пакет metrics собирает метрики Prometheus для HTTP API и хранилища.
+package metrics
+
+import (
+	"go-news/pkg/internal/httpx"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute - метка маршрута для запросов, не попавших ни в один маршрут.
+// Подставлять путь запроса нельзя: число меток стало бы неограниченным.
+const unmatchedRoute = "unmatched"
+
+// Metrics - набор метрик сервиса со своим реестром.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	storageDuration *prometheus.HistogramVec
+	storageErrors   *prometheus.CounterVec
+}
+
+// New создаёт метрики и регистрирует их вместе со стандартными метриками
+// процесса и среды выполнения Go.
+func New() *Metrics {
+	m := Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by route, method and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "storage_operation_duration_seconds",
+			Help:    "Storage operation latency by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		storageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "storage_operation_errors_total",
+			Help: "Number of failed storage operations by method and error kind.",
+		}, []string{"method", "error"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.storageDuration,
+		m.storageErrors,
+	)
+	return &m
+}
+
+// MustRegister добавляет в реестр дополнительные сборщики метрик.
+// Паникует, если метрика с таким именем уже зарегистрирована.
+func (m *Metrics) MustRegister(cs ...prometheus.Collector) {
+	m.registry.MustRegister(cs...)
+}
+
+// Handler отдаёт метрики в формате Prometheus.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware считает запросы и время их обработки. Метка route - шаблон
+// маршрута mux, поэтому middleware подключается через mux.Router.Use.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := httpx.NewRecorder(w)
+		next.ServeHTTP(sw, r)
+
+		route := unmatchedRoute
+		if cur := mux.CurrentRoute(r); cur != nil {
+			if tpl, err := cur.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		status := strconv.Itoa(sw.Status)
+		m.requests.WithLabelValues(route, r.Method, status).Inc()
+		m.requestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
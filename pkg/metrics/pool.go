@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolStater - хранилище с пулом соединений pgx.
+type PoolStater interface {
+	Stat() *pgxpool.Stat
+}
+
+// poolCollector отдаёт статистику пула соединений PostgreSQL в момент сбора.
+type poolCollector struct {
+	pool PoolStater
+
+	acquired    *prometheus.Desc
+	idle        *prometheus.Desc
+	total       *prometheus.Desc
+	max         *prometheus.Desc
+	acquires    *prometheus.Desc
+	acquireTime *prometheus.Desc
+	emptyWaits  *prometheus.Desc
+}
+
+// NewPoolCollector создаёт сборщик статистики пула соединений.
+func NewPoolCollector(pool PoolStater) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("db_pool_"+name, help, nil, nil)
+	}
+	return &poolCollector{
+		pool:        pool,
+		acquired:    desc("acquired_connections", "Number of connections currently in use."),
+		idle:        desc("idle_connections", "Number of idle connections in the pool."),
+		total:       desc("total_connections", "Total number of connections in the pool."),
+		max:         desc("max_connections", "Maximum size of the pool."),
+		acquires:    desc("acquires_total", "Number of successful connection acquires."),
+		acquireTime: desc("acquire_duration_seconds_total", "Total time spent acquiring connections."),
+		emptyWaits:  desc("empty_acquires_total", "Number of acquires that waited for a free connection."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquired
+	ch <- c.idle
+	ch <- c.total
+	ch <- c.max
+	ch <- c.acquires
+	ch <- c.acquireTime
+	ch <- c.emptyWaits
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireTime, prometheus.CounterValue, s.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyWaits, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+}
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"go-news/pkg/storage"
+	"time"
+)
+
+// Store - хранилище, записывающее время выполнения и ошибки каждого метода.
+type Store struct {
+	db storage.Interface
+	m  *Metrics
+}
+
+// Storage оборачивает db, добавляя метрики хранилища.
+func (m *Metrics) Storage(db storage.Interface) *Store {
+	return &Store{db: db, m: m}
+}
+
+// observe записывает метрики операции method, начатой в start.
+func (s *Store) observe(method string, start time.Time, err error) {
+	s.m.storageDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		s.m.storageErrors.WithLabelValues(method, errorKind(err)).Inc()
+	}
+}
+
+func (s *Store) Tasks(ctx context.Context, q storage.TaskQuery) (storage.TaskPage, error) {
+	start := time.Now()
+	page, err := s.db.Tasks(ctx, q)
+	s.observe("tasks", start, err)
+	return page, err
+}
+
+func (s *Store) Task(ctx context.Context, id int) (storage.Task, error) {
+	start := time.Now()
+	t, err := s.db.Task(ctx, id)
+	s.observe("task", start, err)
+	return t, err
+}
+
+func (s *Store) SearchTasks(ctx context.Context, query string, limit int) ([]storage.SearchResult, error) {
+	start := time.Now()
+	res, err := s.db.SearchTasks(ctx, query, limit)
+	s.observe("search_tasks", start, err)
+	return res, err
+}
+
+func (s *Store) AddTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	start := time.Now()
+	t, err := s.db.AddTask(ctx, t)
+	s.observe("add_task", start, err)
+	return t, err
+}
+
+func (s *Store) UpdateTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	start := time.Now()
+	t, err := s.db.UpdateTask(ctx, t)
+	s.observe("update_task", start, err)
+	return t, err
+}
+
+func (s *Store) DeleteTask(ctx context.Context, t storage.Task) error {
+	start := time.Now()
+	err := s.db.DeleteTask(ctx, t)
+	s.observe("delete_task", start, err)
+	return err
+}
+
+func (s *Store) RestoreTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	start := time.Now()
+	t, err := s.db.RestoreTask(ctx, t)
+	s.observe("restore_task", start, err)
+	return t, err
+}
+
+func (s *Store) PurgeTasks(ctx context.Context, deletedBefore int64) (int, error) {
+	start := time.Now()
+	n, err := s.db.PurgeTasks(ctx, deletedBefore)
+	s.observe("purge_tasks", start, err)
+	return n, err
+}
+
+func (s *Store) TaskHistory(ctx context.Context, id int) ([]storage.HistoryEntry, error) {
+	start := time.Now()
+	entries, err := s.db.TaskHistory(ctx, id)
+	s.observe("task_history", start, err)
+	return entries, err
+}
+
+func (s *Store) AddAPIKey(ctx context.Context, k storage.APIKey) (storage.APIKey, error) {
+	start := time.Now()
+	k, err := s.db.AddAPIKey(ctx, k)
+	s.observe("add_api_key", start, err)
+	return k, err
+}
+
+func (s *Store) APIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	start := time.Now()
+	keys, err := s.db.APIKeys(ctx)
+	s.observe("api_keys", start, err)
+	return keys, err
+}
+
+func (s *Store) APIKeyByHash(ctx context.Context, hash string) (storage.APIKey, error) {
+	start := time.Now()
+	k, err := s.db.APIKeyByHash(ctx, hash)
+	s.observe("api_key_by_hash", start, err)
+	return k, err
+}
+
+func (s *Store) RevokeAPIKey(ctx context.Context, id int) error {
+	start := time.Now()
+	err := s.db.RevokeAPIKey(ctx, id)
+	s.observe("revoke_api_key", start, err)
+	return err
+}
+
+func (s *Store) TouchAPIKey(ctx context.Context, id int, at int64) error {
+	start := time.Now()
+	err := s.db.TouchAPIKey(ctx, id, at)
+	s.observe("touch_api_key", start, err)
+	return err
+}
+
+// Ping проверяет исходное хранилище, если оно реализует storage.Pinger.
+// Иначе хранилище считается доступным.
+func (s *Store) Ping(ctx context.Context) error {
+	pinger, ok := s.db.(storage.Pinger)
+	if !ok {
+		return nil
+	}
+	start := time.Now()
+	err := pinger.Ping(ctx)
+	s.observe("ping", start, err)
+	return err
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// errorKind возвращает вид ошибки хранилища для метки error.
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, storage.ErrConflict):
+		return "conflict"
+	case errors.Is(err, storage.ErrInvalid):
+		return "invalid"
+	case errors.Is(err, storage.ErrVersionMismatch):
+		return "version_mismatch"
+	case errors.Is(err, storage.ErrHistoryNotRecorded):
+		return "history"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return "other"
+}
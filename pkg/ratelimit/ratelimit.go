@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Off - значение лимита, отключающее ограничение.
+	Off = "off"
+	// Default - имя лимита для маршрутов, у которых нет своего лимита.
+	// Свои лимиты маршрутов называются "МЕТОД /шаблон/пути".
+	Default = "default"
+)
+
+// Limit - не больше Requests запросов за Period. Запросы расходуют токены
+// корзины ёмкостью Requests, которая равномерно пополняется за Period.
+// Нулевой Limit запросы не ограничивает.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit разбирает лимит вида "60/1m", "10/s" или Off.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == Off {
+		return Limit{}, nil
+	}
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q: want <requests>/<period>, e.g. 60/1m", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("rate limit %q: requests must be a positive integer", s)
+	}
+	// "10/s" - то же, что "10/1s"
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid period", s)
+	}
+	return Limit{Requests: n, Period: d}, nil
+}
+
+// Unlimited сообщает, что лимит не ограничивает запросы.
+func (l Limit) Unlimited() bool {
+	return l.Requests == 0
+}
+
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return Off
+	}
+	return strconv.Itoa(l.Requests) + "/" + l.Period.String()
+}
+
+// rate - скорость пополнения корзины в токенах в секунду.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result - решение по запросу и состояние корзины клиента после него.
+type Result struct {
+	Allowed bool
+	// Remaining - сколько запросов ещё можно сделать сразу.
+	Remaining int
+	// RetryAfter - через сколько будет разрешён следующий запрос, если
+	// этот отклонён.
+	RetryAfter time.Duration
+	// Reset - через сколько корзина наполнится полностью.
+	Reset time.Duration
+}
+
+// Limiter ограничивает запросы каждого клиента одним лимитом. Корзины
+// клиентов, не обращавшихся дольше Period, удаляются: они уже полны.
+type Limiter struct {
+	limit Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// New создаёт Limiter с лимитом l.
+func New(l Limit) *Limiter {
+	return &Limiter{
+		limit:   l,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Limit возвращает лимит, с которым создан Limiter.
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow расходует токен клиента key, если он есть.
+func (l *Limiter) Allow(key string) Result {
+	if l.limit.Unlimited() {
+		return Result{Allowed: true}
+	}
+	now := time.Now()
+	capacity, rate := float64(l.limit.Requests), l.limit.rate()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) >= l.limit.Period {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	var res Result
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((capacity - b.tokens) / rate)
+	return res
+}
+
+// sweep удаляет корзины, наполнившиеся к моменту now. Вызывается под l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= l.limit.Period {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,27 @@
+package storage
+
+// Области доступа ключей API.
+const (
+	// ScopeRead разрешает чтение задач.
+	ScopeRead = "read"
+	// ScopeWrite разрешает создание и изменение задач.
+	ScopeWrite = "write"
+	// ScopeAdmin разрешает всё, в том числе удаление задач и управление ключами.
+	ScopeAdmin = "admin"
+)
+
+// APIKey - ключ API сервисного клиента. Сам ключ не хранится: хранилище
+// знает только его хеш, по которому ключ находят при аутентификации.
+type APIKey struct {
+	ID     int      `json:"id" bson:"id"`
+	Name   string   `json:"name" bson:"name"`
+	Scopes []string `json:"scopes" bson:"scopes"`
+	// Hash - SHA-256 ключа в hex, в ответы API не попадает.
+	Hash      string `json:"-" bson:"hash"`
+	CreatedBy string `json:"created_by" bson:"created_by"`
+	// CreatedAt, LastUsedAt и RevokedAt - Unix, секунды; nil - ключ ещё не
+	// использовался или не отозван.
+	CreatedAt  int64  `json:"created_at" bson:"created_at"`
+	LastUsedAt *int64 `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+	RevokedAt  *int64 `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
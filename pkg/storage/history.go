@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// Действия, которые записываются в историю задачи.
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	// ActionPurge - окончательное удаление задачи из корзины. История
+	// задачи после него сохраняется.
+	ActionPurge = "purge"
+)
+
+// AnonymousActor - автор изменений, если в контексте он не указан.
+const AnonymousActor = "anonymous"
+
+// HistoryEntry - запись истории изменений задачи.
+type HistoryEntry struct {
+	TaskID int `json:"task_id" bson:"task_id"`
+	// Version - версия задачи после изменения.
+	Version int    `json:"version" bson:"version"`
+	Action  string `json:"action" bson:"action"`
+	Actor   string `json:"actor" bson:"actor"`
+	// At - время изменения (Unix, секунды).
+	At int64 `json:"at" bson:"at"`
+	// Changes - изменённые поля задачи по ключам JSON.
+	Changes map[string]Change `json:"changes" bson:"changes"`
+}
+
+// Change - значения поля до и после изменения; nil - поля не было
+// (например, before при создании задачи).
+type Change struct {
+	Before any `json:"before" bson:"before"`
+	After  any `json:"after" bson:"after"`
+}
+
+type actorKey struct{}
+
+// WithActor возвращает контекст, изменения в котором записываются в историю
+// от имени actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom возвращает автора изменений из контекста или AnonymousActor.
+func ActorFrom(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
+
+// NewHistoryEntry описывает изменение задачи before на after (nil before -
+// создание) действием action от имени автора из ctx.
+func NewHistoryEntry(ctx context.Context, action string, before *Task, after Task) HistoryEntry {
+	return HistoryEntry{
+		TaskID:  after.ID,
+		Version: after.Version,
+		Action:  action,
+		Actor:   ActorFrom(ctx),
+		At:      time.Now().Unix(),
+		Changes: Diff(before, &after),
+	}
+}
+
+// NewPurgeEntry описывает окончательное удаление задачи t от имени автора
+// из ctx: все поля задачи переходят в Before, версия - следующая за
+// последней версией задачи.
+func NewPurgeEntry(ctx context.Context, t Task) HistoryEntry {
+	return HistoryEntry{
+		TaskID:  t.ID,
+		Version: t.Version + 1,
+		Action:  ActionPurge,
+		Actor:   ActorFrom(ctx),
+		At:      time.Now().Unix(),
+		Changes: Diff(&t, nil),
+	}
+}
+
+// Diff возвращает поля, значения которых в before и after различаются.
+// ID и версия не сравниваются: они есть в самой записи истории.
+func Diff(before, after *Task) map[string]Change {
+	b, a := fields(before), fields(after)
+	changes := make(map[string]Change)
+	for k, v := range a {
+		if !reflect.DeepEqual(b[k], v) {
+			changes[k] = Change{Before: b[k], After: v}
+		}
+	}
+	for k, v := range b {
+		if _, ok := a[k]; !ok {
+			changes[k] = Change{Before: v}
+		}
+	}
+	return changes
+}
+
+// fields возвращает поля задачи t по ключам JSON, nil для nil t.
+func fields(t *Task) map[string]any {
+	if t == nil {
+		return nil
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	delete(m, "id")
+	delete(m, "version")
+	return m
+}
@@ -1,61 +1,292 @@
-package memdb
-
-import "go-news/pkg/storage"
-
-type Store struct{}
-
-func New() *Store {
-	return new(Store)
-}
-
-func (s *Store) Tasks() ([]storage.Task, error) {
-	return posts, nil
-}
-
-func (s *Store) AddTask(p storage.Task) error {
-	posts = append(posts, p)
-	return nil
-}
-
-func (s *Store) UpdateTask(p storage.Task) error {
-	for i := range posts {
-		if posts[i].ID == p.ID {
-			posts[i].ResponsibleID = p.ResponsibleID
-			posts[i].ResponsibleName = p.ResponsibleName
-			posts[i].Context = p.Context
-			posts[i].AssignedAt = p.AssignedAt
-			posts[i].DueDate = p.DueDate
-			return nil
-		}
-	}
-	return nil
-}
-
-func (s *Store) DeleteTask(p storage.Task) error {
-	for i := range posts {
-		if posts[i].ID == p.ID {
-			posts = append(posts[:i], posts[i+1:]...)
-			return nil
-		}
-	}
-	return nil
-}
-
-var posts = []storage.Task{
-	{
-		ID:              1,
-		ResponsibleID:   10,
-		ResponsibleName: "Иван",
-		Context:         "Test 1 Content",
-		AssignedAt:      0,
-		DueDate:         0,
-	},
-	{
-		ID:              2,
-		ResponsibleID:   11,
-		ResponsibleName: "Пётр",
-		Context:         "Test 2 Content",
-		AssignedAt:      0,
-		DueDate:         0,
-	},
-}
+package memdb
+
+import (
+	"context"
+	"go-news/pkg/storage"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Хранилище данных в памяти.
+type Store struct {
+	mu      sync.Mutex
+	posts   []storage.Task
+	history []storage.HistoryEntry
+	nextID  int
+	keys    []storage.APIKey
+}
+
+// Конструктор объекта хранилища. Хранилище заполняется тестовыми задачами.
+func New() *Store {
+	s := Store{
+		posts: append([]storage.Task(nil), posts...),
+	}
+	for _, p := range s.posts {
+		s.nextID = max(s.nextID, p.ID)
+	}
+	return &s
+}
+
+func (s *Store) Tasks(_ context.Context, q storage.TaskQuery) (storage.TaskPage, error) {
+	cursor, err := q.Normalize()
+	if err != nil {
+		return storage.TaskPage{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var page storage.TaskPage
+	var posts []storage.Task
+	for _, p := range s.posts {
+		if !q.Match(p) {
+			continue
+		}
+		page.Total++
+		if cursor == nil || q.After(p, cursor) {
+			posts = append(posts, p)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return q.Less(posts[i], posts[j])
+	})
+	if len(posts) > q.Limit {
+		posts = posts[:q.Limit]
+		page.NextCursor = q.NextCursor(posts[len(posts)-1])
+	}
+	page.Tasks = posts
+	return page, nil
+}
+
+func (s *Store) Task(_ context.Context, id int) (storage.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.posts {
+		if p.ID == id && p.DeletedAt == nil {
+			return p, nil
+		}
+	}
+	return storage.Task{}, storage.ErrNotFound
+}
+
+// SearchTasks ищет задачи, Context которых содержит хотя бы одно слово
+// запроса. Релевантность - число вхождений слов запроса.
+func (s *Store) SearchTasks(_ context.Context, query string, limit int) ([]storage.SearchResult, error) {
+	terms := storage.SearchTerms(query)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []storage.SearchResult
+	for _, p := range s.posts {
+		n := storage.CountMatches(p.Context, terms)
+		if n == 0 || p.DeletedAt != nil {
+			continue
+		}
+		results = append(results, storage.SearchResult{
+			Task:    p,
+			Rank:    float64(n),
+			Snippet: storage.Highlight(p.Context, terms),
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Rank > results[j].Rank
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// AddTask сохраняет задачу под следующим свободным ID.
+func (s *Store) AddTask(ctx context.Context, p storage.Task) (storage.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	p.ID = s.nextID
+	p.Version = 1
+	s.posts = append(s.posts, p)
+	s.record(ctx, storage.ActionCreate, nil, p)
+	return p, nil
+}
+
+func (s *Store) UpdateTask(ctx context.Context, p storage.Task) (storage.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, err := s.find(p, false)
+	if err != nil {
+		return storage.Task{}, err
+	}
+	before := s.posts[i]
+	p.Version = before.Version + 1
+	p.DeletedAt = nil
+	s.posts[i] = p
+	s.record(ctx, storage.ActionUpdate, &before, p)
+	return p, nil
+}
+
+func (s *Store) DeleteTask(ctx context.Context, p storage.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, err := s.find(p, false)
+	if err != nil {
+		return err
+	}
+	before := s.posts[i]
+	now := time.Now().Unix()
+	s.posts[i].DeletedAt = &now
+	s.posts[i].Version++
+	s.record(ctx, storage.ActionDelete, &before, s.posts[i])
+	return nil
+}
+
+func (s *Store) RestoreTask(ctx context.Context, p storage.Task) (storage.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, err := s.find(p, true)
+	if err != nil {
+		return storage.Task{}, err
+	}
+	before := s.posts[i]
+	s.posts[i].DeletedAt = nil
+	s.posts[i].Version++
+	s.record(ctx, storage.ActionRestore, &before, s.posts[i])
+	return s.posts[i], nil
+}
+
+func (s *Store) PurgeTasks(ctx context.Context, deletedBefore int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.posts[:0]
+	for _, p := range s.posts {
+		if p.DeletedAt == nil || *p.DeletedAt >= deletedBefore {
+			kept = append(kept, p)
+			continue
+		}
+		s.history = append(s.history, storage.NewPurgeEntry(ctx, p))
+	}
+	n := len(s.posts) - len(kept)
+	s.posts = kept
+	return n, nil
+}
+
+func (s *Store) TaskHistory(_ context.Context, id int) ([]storage.HistoryEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := []storage.HistoryEntry{}
+	for _, e := range s.history {
+		if e.TaskID == id {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 && !slices.ContainsFunc(s.posts, func(p storage.Task) bool { return p.ID == id }) {
+		return nil, storage.ErrNotFound
+	}
+	return entries, nil
+}
+
+// AddAPIKey сохраняет ключ под следующим свободным ID.
+func (s *Store) AddAPIKey(_ context.Context, k storage.APIKey) (storage.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range s.keys {
+		if key.Hash == k.Hash {
+			return storage.APIKey{}, storage.ErrConflict
+		}
+	}
+	k.ID = len(s.keys) + 1
+	k.Scopes = slices.Clone(k.Scopes)
+	s.keys = append(s.keys, k)
+	return k, nil
+}
+
+func (s *Store) APIKeys(context.Context) ([]storage.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.keys), nil
+}
+
+func (s *Store) APIKeyByHash(_ context.Context, hash string) (storage.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+	return storage.APIKey{}, storage.ErrNotFound
+}
+
+func (s *Store) RevokeAPIKey(_ context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id < 1 || id > len(s.keys) {
+		return storage.ErrNotFound
+	}
+	if s.keys[id-1].RevokedAt == nil {
+		now := time.Now().Unix()
+		s.keys[id-1].RevokedAt = &now
+	}
+	return nil
+}
+
+func (s *Store) TouchAPIKey(_ context.Context, id int, at int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id < 1 || id > len(s.keys) {
+		return storage.ErrNotFound
+	}
+	s.keys[id-1].LastUsedAt = &at
+	return nil
+}
+
+// record добавляет в историю изменение задачи. Вызывается под s.mu.
+func (s *Store) record(ctx context.Context, action string, before *storage.Task, after storage.Task) {
+	s.history = append(s.history, storage.NewHistoryEntry(ctx, action, before, after))
+}
+
+// find возвращает индекс задачи p.ID - удалённой, если deleted, иначе
+// действующей - и проверяет её версию, если p.Version не 0. Вызывается под s.mu.
+func (s *Store) find(p storage.Task, deleted bool) (int, error) {
+	for i := range s.posts {
+		if s.posts[i].ID != p.ID || (s.posts[i].DeletedAt != nil) != deleted {
+			continue
+		}
+		if p.Version != 0 && s.posts[i].Version != p.Version {
+			return 0, storage.ErrVersionMismatch
+		}
+		return i, nil
+	}
+	return 0, storage.ErrNotFound
+}
+
+// Ping всегда успешен: хранилище в памяти всегда доступно.
+func (s *Store) Ping(context.Context) error {
+	return nil
+}
+
+// Close ничего не делает: хранилищу в памяти нечего освобождать.
+func (s *Store) Close() error {
+	return nil
+}
+
+var posts = []storage.Task{
+	{
+		ID:              1,
+		ResponsibleID:   10,
+		ResponsibleName: "Иван",
+		Context:         "Test 1 Content",
+		AssignedAt:      0,
+		DueDate:         0,
+		Version:         1,
+	},
+	{
+		ID:              2,
+		ResponsibleID:   11,
+		ResponsibleName: "Пётр",
+		Context:         "Test 2 Content",
+		AssignedAt:      0,
+		DueDate:         0,
+		Version:         1,
+	},
+}
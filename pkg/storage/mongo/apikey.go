@@ -0,0 +1,86 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"go-news/pkg/storage"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// keysCollection хранит ключи API; хеш ключа уникален.
+const keysCollection = "api_keys"
+
+// AddAPIKey сохраняет ключ под ID, выделенным из счётчика в коллекции counters.
+func (s *Store) AddAPIKey(ctx context.Context, k storage.APIKey) (storage.APIKey, error) {
+	id, err := s.nextID(ctx, keysCollection)
+	if err != nil {
+		return storage.APIKey{}, err
+	}
+	k.ID = id
+	_, err = s.database().Collection(keysCollection).InsertOne(ctx, k)
+	if err != nil {
+		return storage.APIKey{}, mapError(err)
+	}
+	return k, nil
+}
+
+func (s *Store) APIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "id", Value: 1}})
+	cur, err := s.database().Collection(keysCollection).Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	keys := []storage.APIKey{}
+	if err := cur.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *Store) APIKeyByHash(ctx context.Context, hash string) (storage.APIKey, error) {
+	var k storage.APIKey
+	err := s.database().Collection(keysCollection).
+		FindOne(ctx, bson.D{{Key: "hash", Value: hash}}).Decode(&k)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return storage.APIKey{}, storage.ErrNotFound
+	}
+	return k, err
+}
+
+// RevokeAPIKey отмечает время отзыва ключа, если он ещё не отозван.
+func (s *Store) RevokeAPIKey(ctx context.Context, id int) error {
+	collection := s.database().Collection(keysCollection)
+	_, err := collection.UpdateOne(ctx,
+		bson.D{{Key: "id", Value: id}, {Key: "revoked_at", Value: nil}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "revoked_at", Value: time.Now().Unix()}}}},
+	)
+	if err != nil {
+		return err
+	}
+	n, err := collection.CountDocuments(ctx, bson.D{{Key: "id", Value: id}})
+	switch {
+	case err != nil:
+		return err
+	case n == 0:
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) TouchAPIKey(ctx context.Context, id int, at int64) error {
+	res, err := s.database().Collection(keysCollection).UpdateOne(ctx,
+		bson.D{{Key: "id", Value: id}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "last_used_at", Value: at}}}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
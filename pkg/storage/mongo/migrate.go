@@ -0,0 +1,279 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrationsCollection хранит применённые миграции: {_id: версия, name, applied_at}.
+const migrationsCollection = "schema_migrations"
+
+// migration - изменение коллекций и индексов одной версии схемы. Шаги
+// идемпотентны (создание существующего индекса ничего не делает), поэтому
+// одновременный запуск на нескольких репликах безопасен и без блокировки.
+type migration struct {
+	version  int
+	name     string
+	up, down func(ctx context.Context, db *mongo.Database) error
+}
+
+// migrations - миграции в порядке версий, версии идут подряд с 1.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "posts_text_index",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			// Язык "none" отключает стемминг: задачи пишутся на разных языках.
+			_, err := db.Collection(collectionName).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "context", Value: "text"}},
+				Options: options.Index().SetName("context_text").SetDefaultLanguage("none"),
+			})
+			return err
+		},
+		down: dropIndexes(collectionName, "context_text"),
+	},
+	{
+		version: 2,
+		name:    "posts_indexes",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			// Уникальный id: без него одновременные вставки могли бы
+			// создать дубликаты, а ErrConflict никогда бы не возвращался.
+			_, err := db.Collection(collectionName).Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetName("id_unique").SetUnique(true)},
+				{Keys: bson.D{{Key: "responsible_id", Value: 1}, {Key: "id", Value: 1}}, Options: options.Index().SetName("responsible_id_id")},
+				{Keys: bson.D{{Key: "assigned_at", Value: 1}, {Key: "id", Value: 1}}, Options: options.Index().SetName("assigned_at_id")},
+				{Keys: bson.D{{Key: "due_date", Value: 1}, {Key: "id", Value: 1}}, Options: options.Index().SetName("due_date_id")},
+			})
+			return err
+		},
+		down: dropIndexes(collectionName, "id_unique", "responsible_id_id", "assigned_at_id", "due_date_id"),
+	},
+	{
+		version: 3,
+		name:    "posts_version",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(collectionName).UpdateMany(ctx,
+				bson.D{{Key: "version", Value: bson.D{{Key: "$exists", Value: false}}}},
+				bson.D{{Key: "$set", Value: bson.D{{Key: "version", Value: 1}}}},
+			)
+			return err
+		},
+		down: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(collectionName).UpdateMany(ctx, bson.D{},
+				bson.D{{Key: "$unset", Value: bson.D{{Key: "version", Value: ""}}}},
+			)
+			return err
+		},
+	},
+	{
+		version: 4,
+		name:    "posts_deleted_at_index",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			// Корзина и очистка выбирают только удалённые задачи.
+			_, err := db.Collection(collectionName).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+				Options: options.Index().SetName("deleted_at").SetSparse(true),
+			})
+			return err
+		},
+		down: dropIndexes(collectionName, "deleted_at"),
+	},
+	{
+		version: 5,
+		name:    "task_history",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(historyCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "task_id", Value: 1}, {Key: "version", Value: 1}},
+				Options: options.Index().SetName("task_id_version"),
+			})
+			return err
+		},
+		down: func(ctx context.Context, db *mongo.Database) error {
+			return db.Collection(historyCollection).Drop(ctx)
+		},
+	},
+	{
+		version: 6,
+		name:    "api_keys",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(keysCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetName("id_unique").SetUnique(true)},
+				{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetName("hash_unique").SetUnique(true)},
+			})
+			return err
+		},
+		down: func(ctx context.Context, db *mongo.Database) error {
+			return db.Collection(keysCollection).Drop(ctx)
+		},
+	},
+	{
+		version: 7,
+		name:    "posts_snake_case_fields",
+		up:      renameFields(collectionName, legacyTaskFields),
+		down:    renameFields(collectionName, reversed(legacyTaskFields)),
+	},
+	{
+		version: 8,
+		name:    "posts_id_counter",
+		up:      seedCounter(collectionName),
+		// Счётчик не откатывается: выданные ID не должны выдаваться снова.
+		down: func(context.Context, *mongo.Database) error { return nil },
+	},
+}
+
+// seedCounter возвращает шаг, поднимающий счётчик ID коллекции (см. nextID)
+// до наибольшего id её документов. Иначе счётчик, созданный первой вставкой,
+// начинается с 1 и выдаёт ID уже существующих документов.
+func seedCounter(collection string) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		var last struct {
+			ID int `bson:"id"`
+		}
+		err := db.Collection(collection).FindOne(ctx, bson.D{},
+			options.FindOne().SetSort(bson.D{{Key: "id", Value: -1}}).SetProjection(bson.D{{Key: "id", Value: 1}}),
+		).Decode(&last)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// $max не уменьшает счётчик, если он уже впереди.
+		_, err = db.Collection(countersCollection).UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: collection}},
+			bson.D{{Key: "$max", Value: bson.D{{Key: "seq", Value: last.ID}}}},
+			options.Update().SetUpsert(true),
+		)
+		return err
+	}
+}
+
+// legacyTaskFields - поля задач, которые драйвер до появления тегов bson
+// называл по имени поля Go в нижнем регистре, и их текущие имена.
+var legacyTaskFields = map[string]string{
+	"responsibleid":   "responsible_id",
+	"responsiblename": "responsible_name",
+	"assignedat":      "assigned_at",
+	"duedate":         "due_date",
+}
+
+// renameFields возвращает шаг, переименовывающий поля документов коллекции:
+// fields - старое имя -> новое. Документы без старых полей не меняются.
+func renameFields(collection string, fields map[string]string) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		var exists bson.A
+		rename := bson.D{}
+		for from, to := range fields {
+			exists = append(exists, bson.D{{Key: from, Value: bson.D{{Key: "$exists", Value: true}}}})
+			rename = append(rename, bson.E{Key: from, Value: to})
+		}
+		_, err := db.Collection(collection).UpdateMany(ctx,
+			bson.D{{Key: "$or", Value: exists}},
+			bson.D{{Key: "$rename", Value: rename}},
+		)
+		return err
+	}
+}
+
+// reversed возвращает отображение новое имя -> старое.
+func reversed(fields map[string]string) map[string]string {
+	r := make(map[string]string, len(fields))
+	for from, to := range fields {
+		r[to] = from
+	}
+	return r
+}
+
+// dropIndexes возвращает шаг отката, удаляющий индексы коллекции.
+func dropIndexes(collection string, names ...string) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, name := range names {
+			_, err := db.Collection(collection).Indexes().DropOne(ctx, name)
+			if err != nil && !isNotFound(err) {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// isNotFound сообщает, что удаляемого индекса или коллекции уже нет.
+func isNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Code == 26 || cmdErr.Code == 27 // NamespaceNotFound, IndexNotFound
+	}
+	return false
+}
+
+// MigrateUp применяет недостающие миграции по порядку.
+func (s *Store) MigrateUp(ctx context.Context) error {
+	db := s.database()
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than the application (%d)", current, len(migrations))
+	}
+	for _, m := range migrations[current:] {
+		if err := m.up(ctx, db); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+		}
+		_, err := db.Collection(migrationsCollection).UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: m.version}},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "name", Value: m.name},
+				{Key: "applied_at", Value: time.Now().UTC()},
+			}}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// MigrateDown откатывает n последних миграций в обратном порядке.
+func (s *Store) MigrateDown(ctx context.Context, n int) error {
+	db := s.database()
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than the application (%d)", current, len(migrations))
+	}
+	for v := current; v > 0 && v > current-n; v-- {
+		m := migrations[v-1]
+		if err := m.down(ctx, db); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+		}
+		_, err := db.Collection(migrationsCollection).DeleteOne(ctx, bson.D{{Key: "_id", Value: m.version}})
+		if err != nil {
+			return fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// SchemaVersion возвращает версию последней применённой миграции.
+func (s *Store) SchemaVersion(ctx context.Context) (int, error) {
+	var last struct {
+		Version int `bson:"_id"`
+	}
+	err := s.database().Collection(migrationsCollection).FindOne(ctx, bson.D{},
+		options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}}),
+	).Decode(&last)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return last.Version, err
+}
@@ -1,94 +1,417 @@
-package mongo
-
-import (
-	"context"
-	"go-news/pkg/storage"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-)
-
-// Хранилище данных.
-type Store struct {
-	db *mongo.Client
-}
-
-const (
-	dbName         = "go-news"
-	collectionName = "posts"
-)
-
-// Конструктор объекта хранилища.
-func New(connectionString string) (*Store, error) {
-	mongoOpts := options.Client().ApplyURI(connectionString)
-	client, err := mongo.Connect(context.Background(), mongoOpts)
-	if err != nil {
-		return nil, err
-	}
-	// не отключаем клиент сразу — вернём подключение в Store
-	err = client.Ping(context.Background(), nil)
-	if err != nil {
-		return nil, err
-	}
-	s := Store{
-		db: client,
-	}
-	return &s, err
-}
-
-func (s *Store) Tasks() ([]storage.Task, error) {
-	collection := s.db.Database(dbName).Collection(collectionName)
-	filter := bson.D{}
-	cur, err := collection.Find(context.Background(), filter)
-	if err != nil {
-		return nil, err
-	}
-	defer cur.Close(context.Background())
-	var posts []storage.Task
-	for cur.Next(context.Background()) {
-		var p storage.Task
-		err := cur.Decode(&p)
-		if err != nil {
-			return nil, err
-		}
-		posts = append(posts, p)
-	}
-	return posts, cur.Err()
-}
-
-func (s *Store) AddTask(p storage.Task) error {
-	collection := s.db.Database(dbName).Collection(collectionName)
-	_, err := collection.InsertOne(context.Background(), p)
-	if err != nil {
-		return err
-	}
-	return err
-}
-func (s *Store) UpdateTask(p storage.Task) error {
-	collection := s.db.Database(dbName).Collection(collectionName)
-	filter := bson.D{{Key: "ID", Value: p.ID}}
-	update := bson.D{{Key: "$set",
-		Value: bson.D{
-			{Key: "ResponsibleID", Value: p.ResponsibleID},
-			{Key: "ResponsibleName", Value: p.ResponsibleName},
-			{Key: "Context", Value: p.Context},
-			{Key: "DueDate", Value: p.DueDate},
-			{Key: "AssignedAt", Value: p.AssignedAt},
-		},
-	}}
-	_, err := collection.UpdateOne(context.Background(), filter, update)
-	if err != nil {
-		return err
-	}
-	return err
-}
-func (s *Store) DeleteTask(p storage.Task) error {
-	collection := s.db.Database(dbName).Collection(collectionName)
-	filter := bson.D{{Key: "ID", Value: p.ID}}
-	_, err := collection.DeleteOne(context.Background(), filter)
-	if err != nil {
-		return err
-	}
-	return err
-}
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-news/pkg/storage"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+)
+
+// Хранилище данных.
+type Store struct {
+	db   *mongo.Client
+	name string // имя базы данных
+}
+
+const (
+	dbName             = "go-news"
+	collectionName     = "posts"
+	countersCollection = "counters"
+	historyCollection  = "task_history"
+)
+
+// Конструктор объекта хранилища.
+func New(connectionString string) (*Store, error) {
+	// otelmongo создаёт span для каждой команды (find, insert, update...)
+	// с именем коллекции.
+	mongoOpts := options.Client().ApplyURI(connectionString).SetMonitor(otelmongo.NewMonitor())
+	client, err := mongo.Connect(context.Background(), mongoOpts)
+	if err != nil {
+		return nil, err
+	}
+	// не отключаем клиент сразу — вернём подключение в Store
+	err = client.Ping(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	s := Store{
+		db:   client,
+		name: dbName,
+	}
+	return &s, nil
+}
+
+// database возвращает базу данных хранилища.
+func (s *Store) database() *mongo.Database {
+	return s.db.Database(s.name)
+}
+
+// Tasks возвращает страницу задач. Фильтры, сортировка и пагинация
+// выполняются в запросе; пагинация - по ключу (значение сортировки, id).
+func (s *Store) Tasks(ctx context.Context, q storage.TaskQuery) (storage.TaskPage, error) {
+	cursor, err := q.Normalize()
+	if err != nil {
+		return storage.TaskPage{}, err
+	}
+	collection := s.database().Collection(collectionName)
+	filter := bson.D{notDeleted}
+	if q.Deleted {
+		filter = bson.D{deleted}
+	}
+	if q.ResponsibleID != nil {
+		filter = append(filter, bson.E{Key: "responsible_id", Value: *q.ResponsibleID})
+	}
+	if r := rangeFilter(q.DueFrom, q.DueTo); r != nil {
+		filter = append(filter, bson.E{Key: "due_date", Value: r})
+	}
+	if r := rangeFilter(q.AssignedFrom, q.AssignedTo); r != nil {
+		filter = append(filter, bson.E{Key: "assigned_at", Value: r})
+	}
+
+	var page storage.TaskPage
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return storage.TaskPage{}, err
+	}
+	page.Total = int(total)
+
+	dir, op := 1, "$gt"
+	if q.Desc {
+		dir, op = -1, "$lt"
+	}
+	sort := bson.D{{Key: "id", Value: dir}}
+	if q.Sort != storage.SortID {
+		sort = append(bson.D{{Key: q.Sort, Value: dir}}, sort...)
+	}
+	if cursor != nil {
+		after := bson.E{Key: "id", Value: bson.D{{Key: op, Value: cursor.ID}}}
+		if q.Sort != storage.SortID {
+			after = bson.E{Key: "$or", Value: bson.A{
+				bson.D{{Key: q.Sort, Value: bson.D{{Key: op, Value: cursor.Value}}}},
+				bson.D{{Key: q.Sort, Value: cursor.Value}, after},
+			}}
+		}
+		filter = append(filter, after)
+	}
+
+	opts := options.Find().SetSort(sort).SetLimit(int64(q.Limit + 1))
+	cur, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return storage.TaskPage{}, err
+	}
+	defer cur.Close(ctx)
+	posts := []storage.Task{}
+	for cur.Next(ctx) {
+		var p storage.Task
+		err := cur.Decode(&p)
+		if err != nil {
+			return storage.TaskPage{}, err
+		}
+		posts = append(posts, p)
+	}
+	if err = cur.Err(); err != nil {
+		return storage.TaskPage{}, err
+	}
+	if len(posts) > q.Limit {
+		posts = posts[:q.Limit]
+		page.NextCursor = q.NextCursor(posts[len(posts)-1])
+	}
+	page.Tasks = posts
+	return page, nil
+}
+
+// rangeFilter возвращает условие на вхождение в диапазон [from, to] или nil.
+func rangeFilter(from, to *int64) bson.D {
+	var r bson.D
+	if from != nil {
+		r = append(r, bson.E{Key: "$gte", Value: *from})
+	}
+	if to != nil {
+		r = append(r, bson.E{Key: "$lte", Value: *to})
+	}
+	return r
+}
+
+func (s *Store) Task(ctx context.Context, id int) (storage.Task, error) {
+	collection := s.database().Collection(collectionName)
+	filter := bson.D{{Key: "id", Value: id}, notDeleted}
+	var p storage.Task
+	err := collection.FindOne(ctx, filter).Decode(&p)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return storage.Task{}, storage.ErrNotFound
+	}
+	if err != nil {
+		return storage.Task{}, err
+	}
+	return p, nil
+}
+
+// SearchTasks выполняет поиск по текстовому индексу context. Релевантность -
+// textScore MongoDB, фрагменты строятся по словам запроса.
+func (s *Store) SearchTasks(ctx context.Context, query string, limit int) ([]storage.SearchResult, error) {
+	collection := s.database().Collection(collectionName)
+	filter := bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: query}}}, notDeleted}
+	score := bson.D{{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}}}
+	opts := options.Find().
+		SetProjection(score).
+		SetSort(append(score, bson.E{Key: "id", Value: 1})).
+		SetLimit(int64(limit))
+	cur, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	terms := storage.SearchTerms(query)
+	var results []storage.SearchResult
+	for cur.Next(ctx) {
+		var doc struct {
+			storage.Task `bson:",inline"`
+			Score        float64 `bson:"score"`
+		}
+		err := cur.Decode(&doc)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, storage.SearchResult{
+			Task:    doc.Task,
+			Rank:    doc.Score,
+			Snippet: storage.Highlight(doc.Context, terms),
+		})
+	}
+	return results, cur.Err()
+}
+
+// AddTask сохраняет задачу под ID, выделенным из счётчика в коллекции counters.
+func (s *Store) AddTask(ctx context.Context, p storage.Task) (storage.Task, error) {
+	id, err := s.nextID(ctx, collectionName)
+	if err != nil {
+		return storage.Task{}, err
+	}
+	p.ID = id
+	p.Version = 1
+	collection := s.database().Collection(collectionName)
+	_, err = collection.InsertOne(ctx, p)
+	if err != nil {
+		return storage.Task{}, mapError(err)
+	}
+	return p, s.record(ctx, storage.ActionCreate, nil, p)
+}
+
+// nextID атомарно увеличивает счётчик name и возвращает новое значение.
+func (s *Store) nextID(ctx context.Context, name string) (int, error) {
+	collection := s.database().Collection(countersCollection)
+	filter := bson.D{{Key: "_id", Value: name}}
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "seq", Value: 1}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var counter struct {
+		Seq int `bson:"seq"`
+	}
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
+}
+
+// UpdateTask обновляет задачу, отбирая её по ID и версии p.Version.
+func (s *Store) UpdateTask(ctx context.Context, p storage.Task) (storage.Task, error) {
+	collection := s.database().Collection(collectionName)
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "responsible_id", Value: p.ResponsibleID},
+			{Key: "responsible_name", Value: p.ResponsibleName},
+			{Key: "context", Value: p.Context},
+			{Key: "due_date", Value: p.DueDate},
+			{Key: "assigned_at", Value: p.AssignedAt},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	var before storage.Task
+	err := collection.FindOneAndUpdate(ctx, versionFilter(p, false), update, opts).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return storage.Task{}, s.missingOrChanged(ctx, p.ID, false)
+	}
+	if err != nil {
+		return storage.Task{}, mapError(err)
+	}
+	p.Version = before.Version + 1
+	p.DeletedAt = nil
+	return p, s.record(ctx, storage.ActionUpdate, &before, p)
+}
+
+// Условия на пометку об удалении. Поле deleted_at отсутствует у
+// действующих задач, сравнение с null находит и такие документы.
+var (
+	notDeleted = bson.E{Key: "deleted_at", Value: nil}
+	deleted    = bson.E{Key: "deleted_at", Value: bson.D{{Key: "$ne", Value: nil}}}
+)
+
+// DeleteTask помечает задачу удалённой, если её версия совпадает с p.Version.
+func (s *Store) DeleteTask(ctx context.Context, p storage.Task) error {
+	collection := s.database().Collection(collectionName)
+	now := time.Now().Unix()
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "deleted_at", Value: now}}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	var before storage.Task
+	err := collection.FindOneAndUpdate(ctx, versionFilter(p, false), update, opts).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return s.missingOrChanged(ctx, p.ID, false)
+	}
+	if err != nil {
+		return err
+	}
+	after := before
+	after.DeletedAt = &now
+	after.Version++
+	return s.record(ctx, storage.ActionDelete, &before, after)
+}
+
+// RestoreTask снимает с задачи пометку об удалении, если её версия
+// совпадает с p.Version.
+func (s *Store) RestoreTask(ctx context.Context, p storage.Task) (storage.Task, error) {
+	collection := s.database().Collection(collectionName)
+	update := bson.D{
+		{Key: "$unset", Value: bson.D{{Key: "deleted_at", Value: ""}}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	var before storage.Task
+	err := collection.FindOneAndUpdate(ctx, versionFilter(p, true), update, opts).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return storage.Task{}, s.missingOrChanged(ctx, p.ID, true)
+	}
+	if err != nil {
+		return storage.Task{}, err
+	}
+	t := before
+	t.DeletedAt = nil
+	t.Version++
+	return t, s.record(ctx, storage.ActionRestore, &before, t)
+}
+
+// PurgeTasks окончательно удаляет задачи, удалённые раньше deletedBefore,
+// и записывает в историю их последние значения. Задачи удаляются по одной:
+// FindOneAndDelete возвращает ровно тот документ, который удалил. Ошибка
+// записи истории не останавливает очистку и возвращается в конце.
+func (s *Store) PurgeTasks(ctx context.Context, deletedBefore int64) (int, error) {
+	collection := s.database().Collection(collectionName)
+	filter := bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$lt", Value: deletedBefore}}}}
+	n := 0
+	var historyErr error
+	for {
+		var p storage.Task
+		err := collection.FindOneAndDelete(ctx, filter).Decode(&p)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return n, historyErr
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+		if err := s.insertHistory(ctx, storage.NewPurgeEntry(ctx, p)); err != nil && historyErr == nil {
+			historyErr = err
+		}
+	}
+}
+
+// TaskHistory возвращает записи коллекции task_history задачи id.
+func (s *Store) TaskHistory(ctx context.Context, id int) ([]storage.HistoryEntry, error) {
+	collection := s.database().Collection(historyCollection)
+	filter := bson.D{{Key: "task_id", Value: id}}
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: 1}, {Key: "_id", Value: 1}})
+	cur, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	entries := []storage.HistoryEntry{}
+	if err := cur.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		return entries, nil
+	}
+
+	// Задачи, созданные до ведения истории, истории не имеют.
+	n, err := s.database().Collection(collectionName).CountDocuments(ctx, bson.D{{Key: "id", Value: id}})
+	switch {
+	case err != nil:
+		return nil, err
+	case n == 0:
+		return nil, storage.ErrNotFound
+	}
+	return entries, nil
+}
+
+// record записывает изменение задачи в коллекцию task_history.
+func (s *Store) record(ctx context.Context, action string, before *storage.Task, after storage.Task) error {
+	return s.insertHistory(ctx, storage.NewHistoryEntry(ctx, action, before, after))
+}
+
+// insertHistory добавляет запись e в коллекцию task_history. Запись
+// делается после изменения задачи, а не в транзакции: транзакции MongoDB
+// недоступны на одиночном сервере. Поэтому ошибка оборачивается в
+// storage.ErrHistoryNotRecorded - само изменение уже применено.
+func (s *Store) insertHistory(ctx context.Context, e storage.HistoryEntry) error {
+	_, err := s.database().Collection(historyCollection).InsertOne(ctx, e)
+	if err != nil {
+		return fmt.Errorf("%w: %w", storage.ErrHistoryNotRecorded, err)
+	}
+	return nil
+}
+
+// versionFilter отбирает задачу p.ID с версией p.Version (0 - любой),
+// удалённую, если deleted, иначе действующую.
+func versionFilter(p storage.Task, isDeleted bool) bson.D {
+	filter := bson.D{{Key: "id", Value: p.ID}, notDeleted}
+	if isDeleted {
+		filter[1] = deleted
+	}
+	if p.Version != 0 {
+		filter = append(filter, bson.E{Key: "version", Value: p.Version})
+	}
+	return filter
+}
+
+// missingOrChanged объясняет, почему условное изменение задачи id не
+// затронуло документов: задачи (удалённой, если deleted, иначе действующей)
+// нет - ErrNotFound, или её версия другая - ErrVersionMismatch.
+func (s *Store) missingOrChanged(ctx context.Context, id int, isDeleted bool) error {
+	collection := s.database().Collection(collectionName)
+	n, err := collection.CountDocuments(ctx, versionFilter(storage.Task{ID: id}, isDeleted))
+	switch {
+	case err != nil:
+		return err
+	case n > 0:
+		return storage.ErrVersionMismatch
+	}
+	return storage.ErrNotFound
+}
+
+// Ping проверяет доступность primary-узла MongoDB.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.Ping(ctx, readpref.Primary())
+}
+
+// Close отключает клиент MongoDB.
+func (s *Store) Close() error {
+	return s.db.Disconnect(context.Background())
+}
+
+// mapError приводит ошибки драйвера к ошибкам пакета storage.
+func mapError(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w: %w", storage.ErrConflict, err)
+	}
+	return err
+}
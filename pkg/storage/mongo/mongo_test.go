@@ -0,0 +1,127 @@
+package mongo
+
+import (
+	"context"
+	"go-news/pkg/storage"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// testStore подключается к MongoDB из MONGO_TEST_URI и возвращает хранилище
+// с отдельной базой данных, которая удаляется после теста. Без
+// MONGO_TEST_URI тест пропускается.
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+	s, err := New(uri)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	s.name = "go-news-test-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	t.Cleanup(func() {
+		s.database().Drop(context.Background())
+		s.Close()
+	})
+	return s
+}
+
+// Миграция переименовывает поля задач, сохранённых до появления тегов bson
+func TestMigrateLegacyTaskFields(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	_, err := s.database().Collection(collectionName).InsertOne(ctx, bson.D{
+		{Key: "id", Value: 1},
+		{Key: "responsibleid", Value: 7},
+		{Key: "responsiblename", Value: "Ivan"},
+		{Key: "context", Value: "legacy task"},
+		{Key: "assignedat", Value: int64(100)},
+		{Key: "duedate", Value: int64(200)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp() error = %v", err)
+	}
+
+	got, err := s.Task(ctx, 1)
+	if err != nil {
+		t.Fatalf("Task() error = %v", err)
+	}
+	want := storage.Task{ID: 1, ResponsibleID: 7, ResponsibleName: "Ivan", Context: "legacy task", AssignedAt: 100, DueDate: 200, Version: 1}
+	if got != want {
+		t.Errorf("Task() = %+v, want %+v", got, want)
+	}
+	responsible := 7
+	page, err := s.Tasks(ctx, storage.TaskQuery{ResponsibleID: &responsible})
+	if err != nil || page.Total != 1 {
+		t.Errorf("Tasks(responsible_id=7) = %d tasks, %v; want 1", page.Total, err)
+	}
+}
+
+// Новые задачи в коллекции с данными получают ID больше существующих
+func TestAddTaskToExistingCollection(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	// Задачи, созданные до появления счётчика ID
+	for _, id := range []int{1, 2, 5} {
+		_, err := s.database().Collection(collectionName).InsertOne(ctx, storage.Task{ID: id, Context: "existing task", Version: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp() error = %v", err)
+	}
+
+	for _, want := range []int{6, 7} {
+		task, err := s.AddTask(ctx, storage.Task{ResponsibleID: 1, ResponsibleName: "Ivan", Context: "new task", AssignedAt: 100, DueDate: 200})
+		if err != nil {
+			t.Fatalf("AddTask() error = %v", err)
+		}
+		if task.ID != want {
+			t.Errorf("AddTask() ID = %d, want %d", task.ID, want)
+		}
+	}
+	// Повторный запуск миграции не откатывает счётчик назад
+	if err := seedCounter(collectionName)(ctx, s.database()); err != nil {
+		t.Fatalf("seedCounter() error = %v", err)
+	}
+	if task, err := s.AddTask(ctx, storage.Task{ResponsibleID: 1, ResponsibleName: "Ivan", Context: "new task", AssignedAt: 100, DueDate: 200}); err != nil || task.ID != 8 {
+		t.Errorf("AddTask() after reseeding = %d, %v; want 8", task.ID, err)
+	}
+}
+
+// Окончательное удаление задачи записывается в её историю
+func TestPurgeTasksHistory(t *testing.T) {
+	s := testStore(t)
+	ctx := storage.WithActor(context.Background(), "system:purge")
+	if err := s.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp() error = %v", err)
+	}
+	task, err := s.AddTask(ctx, storage.Task{ResponsibleID: 1, ResponsibleName: "Ivan", Context: "old task", AssignedAt: 100, DueDate: 200})
+	if err != nil {
+		t.Fatalf("AddTask() error = %v", err)
+	}
+	if err := s.DeleteTask(ctx, task); err != nil {
+		t.Fatalf("DeleteTask() error = %v", err)
+	}
+	if n, err := s.PurgeTasks(ctx, time.Now().Add(time.Hour).Unix()); err != nil || n != 1 {
+		t.Fatalf("PurgeTasks() = %d, %v; want 1", n, err)
+	}
+
+	entries, err := s.TaskHistory(ctx, task.ID)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("TaskHistory() = %+v, %v; want 3 entries", entries, err)
+	}
+	if e := entries[2]; e.Action != storage.ActionPurge || e.Version != 3 || e.Actor != "system:purge" {
+		t.Errorf("Unexpected purge entry %+v", e)
+	}
+}
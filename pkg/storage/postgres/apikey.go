@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"go-news/pkg/storage"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// AddAPIKey сохраняет ключ; ID выделяется последовательностью api_keys.id.
+func (s *Store) AddAPIKey(ctx context.Context, k storage.APIKey) (storage.APIKey, error) {
+	err := traced(ctx, "api_keys.insert", "INSERT", func(ctx context.Context) error {
+		return s.db.QueryRow(ctx, `
+  INSERT INTO api_keys (name, hash, scopes, created_by, created_at)
+  VALUES ($1, $2, $3, $4, $5)
+  RETURNING id;
+  `,
+			k.Name,
+			k.Hash,
+			k.Scopes,
+			k.CreatedBy,
+			k.CreatedAt,
+		).Scan(&k.ID)
+	})
+	if err != nil {
+		return storage.APIKey{}, mapError(err)
+	}
+	return k, nil
+}
+
+func (s *Store) APIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	keys := []storage.APIKey{}
+	err := traced(ctx, "api_keys.select", "SELECT", func(ctx context.Context) error {
+		rows, err := s.db.Query(ctx, `
+  SELECT
+   id,
+   name,
+   hash,
+   scopes,
+   created_by,
+   created_at,
+   last_used_at,
+   revoked_at
+  FROM api_keys
+  ORDER BY id;
+ `)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			k, err := scanAPIKey(rows)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, k)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *Store) APIKeyByHash(ctx context.Context, hash string) (storage.APIKey, error) {
+	var k storage.APIKey
+	err := traced(ctx, "api_keys.get", "SELECT", func(ctx context.Context) (err error) {
+		k, err = scanAPIKey(s.db.QueryRow(ctx, `
+  SELECT
+   id,
+   name,
+   hash,
+   scopes,
+   created_by,
+   created_at,
+   last_used_at,
+   revoked_at
+  FROM api_keys
+  WHERE hash = $1;
+ `, hash))
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.APIKey{}, storage.ErrNotFound
+	}
+	return k, err
+}
+
+// RevokeAPIKey отмечает время отзыва ключа, если он ещё не отозван.
+func (s *Store) RevokeAPIKey(ctx context.Context, id int) error {
+	var commandTag pgconn.CommandTag
+	err := traced(ctx, "api_keys.revoke", "UPDATE", func(ctx context.Context) (err error) {
+		commandTag, err = s.db.Exec(ctx, `
+  UPDATE api_keys SET
+   revoked_at = coalesce(revoked_at, $2)
+  WHERE id = $1;
+  `,
+			id,
+			time.Now().Unix(),
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if commandTag.RowsAffected() != 1 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) TouchAPIKey(ctx context.Context, id int, at int64) error {
+	var commandTag pgconn.CommandTag
+	err := traced(ctx, "api_keys.touch", "UPDATE", func(ctx context.Context) (err error) {
+		commandTag, err = s.db.Exec(ctx, `
+  UPDATE api_keys SET
+   last_used_at = $2
+  WHERE id = $1;
+  `,
+			id,
+			at,
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if commandTag.RowsAffected() != 1 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// scanAPIKey читает ключ из строки результата с колонками в порядке APIKey.
+func scanAPIKey(row pgx.Row) (storage.APIKey, error) {
+	var k storage.APIKey
+	err := row.Scan(
+		&k.ID,
+		&k.Name,
+		&k.Hash,
+		&k.Scopes,
+		&k.CreatedBy,
+		&k.CreatedAt,
+		&k.LastUsedAt,
+		&k.RevokedAt,
+	)
+	return k, err
+}
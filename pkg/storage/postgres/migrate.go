@@ -0,0 +1,211 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Миграции лежат в migrations/NNNN_название.up.sql и NNNN_название.down.sql.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID - ключ pg_advisory_lock, под которым выполняются миграции,
+// чтобы несколько запущенных реплик не применяли их одновременно.
+const migrationLockID int64 = 0x676f2d6e657773 // "go-news"
+
+// migration - пара SQL-скриптов одной версии схемы.
+type migration struct {
+	version  int
+	name     string
+	up, down string
+}
+
+// loadMigrations читает миграции из каталога migrations в fsys (встроенные -
+// из migrationFiles), упорядоченные по версии. Версии должны идти подряд
+// с 1, у каждой должны быть up и down.
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*migration{}
+	for _, e := range entries {
+		base, ok := strings.CutSuffix(e.Name(), ".sql")
+		if !ok {
+			continue
+		}
+		dot := strings.LastIndexByte(base, '.')
+		num, name, ok := strings.Cut(base[:max(dot, 0)], "_")
+		version, err := strconv.Atoi(num)
+		if dot < 0 || !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must be NNNN_name.up.sql or NNNN_name.down.sql", e.Name())
+		}
+		body, err := fs.ReadFile(fsys, "migrations/"+e.Name())
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m != nil && m.name != name {
+			return nil, fmt.Errorf("migration %s: duplicate version %d", e.Name(), version)
+		}
+		if m == nil {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		}
+		script := &m.up
+		switch base[dot+1:] {
+		case "up":
+		case "down":
+			script = &m.down
+		default:
+			return nil, fmt.Errorf("migration %s: unknown direction", e.Name())
+		}
+		if *script != "" {
+			return nil, fmt.Errorf("migration %s: duplicate version %d", e.Name(), version)
+		}
+		*script = string(body)
+	}
+
+	ms := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		ms = append(ms, *m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].version < ms[j].version })
+	for i, m := range ms {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d: both up and down scripts are required", m.version)
+		}
+	}
+	return ms, nil
+}
+
+// MigrateUp применяет недостающие миграции по порядку, каждую в своей транзакции.
+func (s *Store) MigrateUp(ctx context.Context) error {
+	ms, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return err
+	}
+	return s.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		current, err := schemaVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if current > len(ms) {
+			return fmt.Errorf("database schema version %d is newer than the application (%d)", current, len(ms))
+		}
+		for _, m := range ms[current:] {
+			if err := applyMigration(ctx, conn, m, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateDown откатывает n последних миграций в обратном порядке.
+func (s *Store) MigrateDown(ctx context.Context, n int) error {
+	ms, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return err
+	}
+	return s.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		current, err := schemaVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if current > len(ms) {
+			return fmt.Errorf("database schema version %d is newer than the application (%d)", current, len(ms))
+		}
+		for v := current; v > 0 && v > current-n; v-- {
+			if err := applyMigration(ctx, conn, ms[v-1], false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SchemaVersion возвращает версию последней применённой миграции. Она
+// ничего не изменяет в базе и не ждёт блокировки миграций: без таблицы
+// schema_migrations версия - 0.
+func (s *Store) SchemaVersion(ctx context.Context) (int, error) {
+	var exists bool
+	err := s.db.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL;`).Scan(&exists)
+	if err != nil || !exists {
+		return 0, err
+	}
+	var version int
+	err = s.db.QueryRow(ctx, `SELECT coalesce(max(version), 0) FROM schema_migrations;`).Scan(&version)
+	return version, err
+}
+
+// withMigrationLock выполняет f на отдельном соединении под advisory lock,
+// предварительно создав таблицу schema_migrations.
+func (s *Store) withMigrationLock(ctx context.Context, f func(conn *pgxpool.Conn) error) error {
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	// Блокировка сессионная: её нужно снять на том же соединении.
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1);`, migrationLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		_, _ = conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1);`, migrationLockID)
+	}()
+
+	_, err = conn.Exec(ctx, `
+  CREATE TABLE IF NOT EXISTS schema_migrations (
+   version INTEGER PRIMARY KEY,
+   name TEXT NOT NULL,
+   applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+  );
+ `)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return f(conn)
+}
+
+func schemaVersion(ctx context.Context, conn *pgxpool.Conn) (int, error) {
+	var version int
+	err := conn.QueryRow(ctx, `SELECT coalesce(max(version), 0) FROM schema_migrations;`).Scan(&version)
+	return version, err
+}
+
+// applyMigration применяет (up) или откатывает миграцию m в одной
+// транзакции с записью в schema_migrations.
+func applyMigration(ctx context.Context, conn *pgxpool.Conn, m migration, up bool) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	script, record, args := m.down, `DELETE FROM schema_migrations WHERE version = $1;`, []any{m.version}
+	if up {
+		script, record, args = m.up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2);`, []any{m.version, m.name}
+	}
+	if _, err = tx.Exec(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+	}
+	if _, err = tx.Exec(ctx, record, args...); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+	}
+	return tx.Commit(ctx)
+}
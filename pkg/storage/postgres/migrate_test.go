@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Миграции читаются по порядку версий парами up и down
+func TestLoadMigrations(t *testing.T) {
+	file := func(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "ordered by version number",
+			files: fstest.MapFS{
+				"migrations/10_ten.up.sql":       file("up 10"),
+				"migrations/10_ten.down.sql":     file("down 10"),
+				"migrations/2_two.up.sql":        file("up 2"),
+				"migrations/2_two.down.sql":      file("down 2"),
+				"migrations/0001_one.up.sql":     file("up 1"),
+				"migrations/0001_one.down.sql":   file("down 1"),
+				"migrations/0003_three.up.sql":   file("up 3"),
+				"migrations/0003_three.down.sql": file("down 3"),
+				"migrations/0004_four.up.sql":    file("up 4"),
+				"migrations/0004_four.down.sql":  file("down 4"),
+				"migrations/0005_five.up.sql":    file("up 5"),
+				"migrations/0005_five.down.sql":  file("down 5"),
+				"migrations/0006_six.up.sql":     file("up 6"),
+				"migrations/0006_six.down.sql":   file("down 6"),
+				"migrations/0007_seven.up.sql":   file("up 7"),
+				"migrations/0007_seven.down.sql": file("down 7"),
+				"migrations/0008_eight.up.sql":   file("up 8"),
+				"migrations/0008_eight.down.sql": file("down 8"),
+				"migrations/0009_nine.up.sql":    file("up 9"),
+				"migrations/0009_nine.down.sql":  file("down 9"),
+				"migrations/README.md":           file("not a migration"),
+			},
+			want: []string{"1_one", "2_two", "3_three", "4_four", "5_five", "6_six", "7_seven", "8_eight", "9_nine", "10_ten"},
+		},
+		{
+			name: "missing down",
+			files: fstest.MapFS{
+				"migrations/0001_one.up.sql": file("up 1"),
+			},
+			wantErr: "both up and down",
+		},
+		{
+			name: "missing version",
+			files: fstest.MapFS{
+				"migrations/0001_one.up.sql":     file("up 1"),
+				"migrations/0001_one.down.sql":   file("down 1"),
+				"migrations/0003_three.up.sql":   file("up 3"),
+				"migrations/0003_three.down.sql": file("down 3"),
+			},
+			wantErr: "migration 2 is missing",
+		},
+		{
+			name: "duplicate version with another name",
+			files: fstest.MapFS{
+				"migrations/0001_one.up.sql":   file("up 1"),
+				"migrations/0001_one.down.sql": file("down 1"),
+				"migrations/0001_uno.up.sql":   file("up 1"),
+			},
+			wantErr: "duplicate version 1",
+		},
+		{
+			name: "duplicate version with another number format",
+			files: fstest.MapFS{
+				"migrations/0001_one.up.sql":   file("up 1"),
+				"migrations/0001_one.down.sql": file("down 1"),
+				"migrations/01_one.up.sql":     file("up 1"),
+			},
+			wantErr: "duplicate version 1",
+		},
+		{
+			name: "malformed name",
+			files: fstest.MapFS{
+				"migrations/one.up.sql": file("up 1"),
+			},
+			wantErr: "name must be",
+		},
+		{
+			name: "unknown direction",
+			files: fstest.MapFS{
+				"migrations/0001_one.sideways.sql": file("up 1"),
+			},
+			wantErr: "unknown direction",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms, err := loadMigrations(tt.files)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadMigrations() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadMigrations() error = %v", err)
+			}
+			var got []string
+			for _, m := range ms {
+				got = append(got, fmt.Sprintf("%d_%s", m.version, m.name))
+				if m.up != fmt.Sprint("up ", m.version) || m.down != fmt.Sprint("down ", m.version) {
+					t.Errorf("migration %d: up %q, down %q", m.version, m.up, m.down)
+				}
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("loadMigrations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Встроенные миграции проходят те же проверки
+func TestEmbeddedMigrations(t *testing.T) {
+	if _, err := loadMigrations(migrationFiles); err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+}
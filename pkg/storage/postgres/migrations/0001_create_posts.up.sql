@@ -0,0 +1,9 @@
+-- IF NOT EXISTS: таблица могла быть создана init.sql до появления миграций.
+CREATE TABLE IF NOT EXISTS posts (
+    id SERIAL PRIMARY KEY,
+    responsible_id INTEGER NOT NULL,
+    responsible_name TEXT NOT NULL,
+    context TEXT NOT NULL,
+    assigned_at BIGINT NOT NULL,
+    due_date BIGINT NOT NULL
+);
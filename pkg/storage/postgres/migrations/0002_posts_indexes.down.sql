@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS posts_context_fts_idx;
+DROP INDEX IF EXISTS posts_due_date_idx;
+DROP INDEX IF EXISTS posts_assigned_at_idx;
+DROP INDEX IF EXISTS posts_responsible_id_idx;
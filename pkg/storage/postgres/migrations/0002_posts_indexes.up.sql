@@ -0,0 +1,7 @@
+-- Индексы для фильтров и сортировки GET /posts
+CREATE INDEX IF NOT EXISTS posts_responsible_id_idx ON posts (responsible_id, id);
+CREATE INDEX IF NOT EXISTS posts_assigned_at_idx ON posts (assigned_at, id);
+CREATE INDEX IF NOT EXISTS posts_due_date_idx ON posts (due_date, id);
+
+-- Полнотекстовый индекс для GET /posts/search
+CREATE INDEX IF NOT EXISTS posts_context_fts_idx ON posts USING GIN (to_tsvector('simple', context));
@@ -0,0 +1,2 @@
+-- Версия задачи для оптимистичной блокировки (ETag / If-Match)
+ALTER TABLE posts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
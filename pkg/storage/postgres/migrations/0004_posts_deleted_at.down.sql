@@ -0,0 +1,3 @@
+-- Удалённые задачи при откате стали бы снова видимыми: удаляем их окончательно
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+ALTER TABLE posts DROP COLUMN deleted_at;
@@ -0,0 +1,5 @@
+-- Мягкое удаление: время удаления задачи (Unix, секунды), NULL - не удалена
+ALTER TABLE posts ADD COLUMN deleted_at BIGINT;
+
+-- Корзина и очистка выбирают только удалённые задачи
+CREATE INDEX posts_deleted_at_idx ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,14 @@
+-- История изменений задач. Внешнего ключа на posts нет: история остаётся
+-- и после окончательного удаления задачи.
+CREATE TABLE task_history (
+    id BIGSERIAL PRIMARY KEY,
+    task_id INTEGER NOT NULL,
+    version INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    changed_at BIGINT NOT NULL,
+    changes JSONB NOT NULL
+);
+
+-- GET /posts/{id}/history
+CREATE INDEX task_history_task_id_idx ON task_history (task_id, version);
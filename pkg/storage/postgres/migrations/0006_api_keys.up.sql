@@ -0,0 +1,11 @@
+-- Ключи API сервисных клиентов. Хранится только SHA-256 ключа (hex).
+CREATE TABLE api_keys (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    created_by TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    last_used_at BIGINT,
+    revoked_at BIGINT
+);
@@ -0,0 +1,4 @@
+-- Удаляем начальную задачу, если она не изменялась
+DELETE FROM posts
+WHERE responsible_id = 0 AND responsible_name = 'SergeyKl' AND context = 'DevSecOps'
+  AND assigned_at = 0 AND due_date = 0 AND version = 1;
@@ -0,0 +1,5 @@
+-- Начальная задача, которую раньше добавлял postgres/init.sql. Добавляется
+-- только в пустую таблицу: в существующих базах она уже есть или удалена.
+INSERT INTO posts (responsible_id, responsible_name, context, assigned_at, due_date)
+SELECT 0, 'SergeyKl', 'DevSecOps', 0, 0
+WHERE NOT EXISTS (SELECT 1 FROM posts);
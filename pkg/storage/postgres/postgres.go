@@ -1,163 +1,671 @@
-package postgres
-
-import (
- "context"
- "errors"
- "go-news/pkg/storage"
-
- "github.com/jackc/pgx/v4/pgxpool"
-)
-
-// Хранилище данных.
-type Store struct {
- db *pgxpool.Pool
-}
-
-// Конструктор объекта хранилища.
-func New(connectionString string) (*Store, error) {
- db, err := pgxpool.Connect(context.Background(), connectionString)
- if err != nil {
-  return nil, err
- }
- s := Store{
-  db: db,
- }
- return &s, nil
-}
-
-func (s *Store) Tasks() ([]storage.Task, error) {
- rows, err := s.db.Query(context.Background(), `
-  SELECT
-   id,
-   responsible_id,
-   responsible_name,
-   context,
-   assigned_at,
-   due_date
-  FROM posts
-  ORDER BY id;
- `)
- if err != nil {
-  return nil, err
- }
- var posts []storage.Task
- for rows.Next() {
-  var p storage.Task
-  err = rows.Scan(
-   &p.ID,
-   &p.ResponsibleID,
-   &p.ResponsibleName,
-   &p.Context,
-   &p.AssignedAt,
-   &p.DueDate,
-  )
-  if err != nil {
-   return nil, err
-  }
-  posts = append(posts, p)
- }
- return posts, rows.Err()
-}
-
-func (s *Store) AddTask(p storage.Task) error {
- tx, err := s.db.Begin(context.Background())
- if err != nil {
-  return err
- }
- defer func() {
-  _ = tx.Rollback(context.Background())
- }()
-
- _, err = tx.Exec(context.Background(), `
-  INSERT INTO posts (id, responsible_id, responsible_name, context, assigned_at, due_date)
-  VALUES ($1, $2, $3, $4, $5, $6);
-  `,
-  p.ID,
-  p.ResponsibleID,
-  p.ResponsibleName,
-  p.Context,
-  p.AssignedAt,
-  p.DueDate,
- )
- if err != nil {
-  return err
- }
-
- return tx.Commit(context.Background())
-}
-
-func (s *Store) UpdateTask(p storage.Task) error {
- tx, err := s.db.Begin(context.Background())
- if err != nil {
-  return err
- }
- defer func() {
-  _ = tx.Rollback(context.Background())
- }()
-
- commandTag, err := tx.Exec(context.Background(), `
-  UPDATE posts SET
-   responsible_id = $1,
-   responsible_name = $2,
-   context = $3,
-   due_date = $4
-  WHERE id = $5;
-  `,
-  p.ResponsibleID,
-  p.ResponsibleName,
-  p.Context,
-  p.DueDate,
-  p.ID,
- )
-
- if err != nil {
-  return err
- }
-
- if commandTag.RowsAffected() != 1 {
-  return errors.New("no row found to update")
- }
-
- return tx.Commit(context.Background())
-}
-
-func (s *Store) DeleteTask(p storage.Task) error {
- tx, err := s.db.Begin(context.Background())
- if err != nil {
-  return err
- }
- defer func() {
-  _ = tx.Rollback(context.Background())
- }()
-
- commandTag, err := tx.Exec(context.Background(), `
-  DELETE FROM posts
-  WHERE id = $1;
-  `,
-  p.ID,
- )
-
- if err != nil {
-  return err
- }
-
- if commandTag.RowsAffected() != 1 {
-  return errors.New("no row found to delete")
- }
-
- return tx.Commit(context.Background())
-}
-
-/*var posts = []storage.Post{
- {
-  ID:      1,
-  Title:   "Effective Go",
-  Content: "Go is a new language. Although it borrows ideas from existing languages, it has unusual properties that make effective Go programs different in character from programs written in its relatives. A straightforward translation of a C++ or Java program into Go is unlikely to produce a satisfactory result—Java programs are written in Java, not Go. On the other hand, thinking about the problem from a Go perspective could produce a successful but quite different program. In other words, to write Go well, it's important to understand its properties and idioms. It's also important to know the established conventions for programming in Go, such as naming, formatting, program construction, and so on, so that programs you write will be easy for other Go programmers to understand.",
- },
- {
-  ID:      2,
-  Title:   "The Go Memory Model",
-  Content: "The Go memory model specifies the conditions under which reads of a variable in one goroutine can be guaranteed to observe values produced by writes to the same variable in a different goroutine.",
- },
-}*/
-
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-news/pkg/storage"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Хранилище данных.
+type Store struct {
+	db *pgxpool.Pool
+}
+
+// Конструктор объекта хранилища.
+func New(connectionString string) (*Store, error) {
+	db, err := pgxpool.Connect(context.Background(), connectionString)
+	if err != nil {
+		return nil, err
+	}
+	s := Store{
+		db: db,
+	}
+	return &s, nil
+}
+
+// Tasks возвращает страницу задач. Фильтры, сортировка и пагинация
+// выполняются в запросе; пагинация - по ключу (значение сортировки, id).
+func (s *Store) Tasks(ctx context.Context, q storage.TaskQuery) (storage.TaskPage, error) {
+	cursor, err := q.Normalize()
+	if err != nil {
+		return storage.TaskPage{}, err
+	}
+	var where conditions
+	if q.Deleted {
+		where.add("deleted_at IS NOT NULL")
+	} else {
+		where.add("deleted_at IS NULL")
+	}
+	if q.ResponsibleID != nil {
+		where.add("responsible_id = ?", *q.ResponsibleID)
+	}
+	where.addRange("due_date", q.DueFrom, q.DueTo)
+	where.addRange("assigned_at", q.AssignedFrom, q.AssignedTo)
+
+	// Число задач и страница читаются из одного снимка базы: иначе задачи,
+	// добавленные или удалённые между запросами, рассогласуют total и страницу.
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly, IsoLevel: pgx.RepeatableRead})
+	if err != nil {
+		return storage.TaskPage{}, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var page storage.TaskPage
+	err = traced(ctx, "posts.count", "SELECT", func(ctx context.Context) error {
+		return tx.QueryRow(ctx, `
+  SELECT count(*) FROM posts`+where.sql()+`;
+ `, where.args...).Scan(&page.Total)
+	})
+	if err != nil {
+		return storage.TaskPage{}, err
+	}
+
+	// q.Sort проверен в Normalize и может подставляться в запрос как есть.
+	order, op := "ASC", ">"
+	if q.Desc {
+		order, op = "DESC", "<"
+	}
+	if cursor != nil {
+		where.add("("+q.Sort+", id) "+op+" (?, ?)", cursor.Value, cursor.ID)
+	}
+	args := append(where.args, q.Limit+1)
+	posts := []storage.Task{}
+	err = traced(ctx, "posts.select", "SELECT", func(ctx context.Context) error {
+		rows, err := tx.Query(ctx, `
+  SELECT
+   id,
+   responsible_id,
+   responsible_name,
+   context,
+   assigned_at,
+   due_date,
+   version,
+   deleted_at
+  FROM posts`+where.sql()+`
+  ORDER BY `+q.Sort+` `+order+`, id `+order+`
+  LIMIT $`+strconv.Itoa(len(args))+`;
+ `, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var p storage.Task
+			err = rows.Scan(
+				&p.ID,
+				&p.ResponsibleID,
+				&p.ResponsibleName,
+				&p.Context,
+				&p.AssignedAt,
+				&p.DueDate,
+				&p.Version,
+				&p.DeletedAt,
+			)
+			if err != nil {
+				return err
+			}
+			posts = append(posts, p)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return storage.TaskPage{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return storage.TaskPage{}, err
+	}
+	if len(posts) > q.Limit {
+		posts = posts[:q.Limit]
+		page.NextCursor = q.NextCursor(posts[len(posts)-1])
+	}
+	page.Tasks = posts
+	return page, nil
+}
+
+func (s *Store) Task(ctx context.Context, id int) (storage.Task, error) {
+	var p storage.Task
+	err := traced(ctx, "posts.get", "SELECT", func(ctx context.Context) error {
+		return s.db.QueryRow(ctx, `
+  SELECT
+   id,
+   responsible_id,
+   responsible_name,
+   context,
+   assigned_at,
+   due_date,
+   version
+  FROM posts
+  WHERE id = $1 AND deleted_at IS NULL;
+ `,
+			id,
+		).Scan(
+			&p.ID,
+			&p.ResponsibleID,
+			&p.ResponsibleName,
+			&p.Context,
+			&p.AssignedAt,
+			&p.DueDate,
+			&p.Version,
+		)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.Task{}, storage.ErrNotFound
+	}
+	if err != nil {
+		return storage.Task{}, err
+	}
+	return p, nil
+}
+
+// SearchTasks выполняет полнотекстовый поиск по posts.context. Запрос
+// разбирается websearch_to_tsquery, фрагменты строит ts_headline по
+// экранированному для HTML тексту.
+func (s *Store) SearchTasks(ctx context.Context, query string, limit int) ([]storage.SearchResult, error) {
+	var results []storage.SearchResult
+	err := traced(ctx, "posts.search", "SELECT", func(ctx context.Context) error {
+		rows, err := s.db.Query(ctx, `
+  SELECT
+   id,
+   responsible_id,
+   responsible_name,
+   context,
+   assigned_at,
+   due_date,
+   version,
+   ts_rank(to_tsvector('simple', context), q)::float8 AS rank,
+   ts_headline('simple',
+    replace(replace(replace(context, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+    q, 'StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2')
+  FROM posts, websearch_to_tsquery('simple', $1) AS q
+  WHERE to_tsvector('simple', context) @@ q AND deleted_at IS NULL
+  ORDER BY rank DESC, id
+  LIMIT $2;
+ `,
+			query,
+			limit,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var r storage.SearchResult
+			err = rows.Scan(
+				&r.Task.ID,
+				&r.Task.ResponsibleID,
+				&r.Task.ResponsibleName,
+				&r.Task.Context,
+				&r.Task.AssignedAt,
+				&r.Task.DueDate,
+				&r.Task.Version,
+				&r.Rank,
+				&r.Snippet,
+			)
+			if err != nil {
+				return err
+			}
+			results = append(results, r)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// AddTask сохраняет задачу; ID выделяется последовательностью posts.id.
+func (s *Store) AddTask(ctx context.Context, p storage.Task) (storage.Task, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return storage.Task{}, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	err = traced(ctx, "posts.insert", "INSERT", func(ctx context.Context) error {
+		return tx.QueryRow(ctx, `
+  INSERT INTO posts (responsible_id, responsible_name, context, assigned_at, due_date)
+  VALUES ($1, $2, $3, $4, $5)
+  RETURNING id, version;
+  `,
+			p.ResponsibleID,
+			p.ResponsibleName,
+			p.Context,
+			p.AssignedAt,
+			p.DueDate,
+		).Scan(&p.ID, &p.Version)
+	})
+	if err != nil {
+		return storage.Task{}, mapError(err)
+	}
+	if err = recordHistory(ctx, tx, storage.ActionCreate, nil, p); err != nil {
+		return storage.Task{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return storage.Task{}, err
+	}
+	return p, nil
+}
+
+// UpdateTask обновляет задачу условным UPDATE: строка меняется, только если
+// её версия совпадает с p.Version.
+func (s *Store) UpdateTask(ctx context.Context, p storage.Task) (storage.Task, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return storage.Task{}, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	before, err := lockTask(ctx, tx, p.ID)
+	if err != nil {
+		return storage.Task{}, err
+	}
+	err = traced(ctx, "posts.update", "UPDATE", func(ctx context.Context) error {
+		return tx.QueryRow(ctx, `
+  UPDATE posts SET
+   responsible_id = $1,
+   responsible_name = $2,
+   context = $3,
+   assigned_at = $4,
+   due_date = $5,
+   version = version + 1
+  WHERE id = $6 AND deleted_at IS NULL AND ($7 = 0 OR version = $7)
+  RETURNING version;
+  `,
+			p.ResponsibleID,
+			p.ResponsibleName,
+			p.Context,
+			p.AssignedAt,
+			p.DueDate,
+			p.ID,
+			p.Version,
+		).Scan(&p.Version)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.Task{}, missingOrChanged(ctx, tx, p.ID, false)
+	}
+	if err != nil {
+		return storage.Task{}, mapError(err)
+	}
+	p.DeletedAt = nil
+	if err = recordHistory(ctx, tx, storage.ActionUpdate, &before, p); err != nil {
+		return storage.Task{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return storage.Task{}, err
+	}
+	return p, nil
+}
+
+// DeleteTask помечает задачу удалённой, если её версия совпадает с p.Version.
+func (s *Store) DeleteTask(ctx context.Context, p storage.Task) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	before, err := lockTask(ctx, tx, p.ID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	var commandTag pgconn.CommandTag
+	err = traced(ctx, "posts.soft_delete", "UPDATE", func(ctx context.Context) (err error) {
+		commandTag, err = tx.Exec(ctx, `
+  UPDATE posts SET
+   deleted_at = $3,
+   version = version + 1
+  WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2);
+  `,
+			p.ID,
+			p.Version,
+			now,
+		)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if commandTag.RowsAffected() != 1 {
+		return missingOrChanged(ctx, tx, p.ID, false)
+	}
+	after := before
+	after.DeletedAt = &now
+	after.Version++
+	if err = recordHistory(ctx, tx, storage.ActionDelete, &before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RestoreTask снимает с задачи пометку об удалении, если её версия
+// совпадает с p.Version.
+func (s *Store) RestoreTask(ctx context.Context, p storage.Task) (storage.Task, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return storage.Task{}, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	before, err := lockTask(ctx, tx, p.ID)
+	if err != nil {
+		return storage.Task{}, err
+	}
+	var t storage.Task
+	err = traced(ctx, "posts.restore", "UPDATE", func(ctx context.Context) error {
+		return tx.QueryRow(ctx, `
+  UPDATE posts SET
+   deleted_at = NULL,
+   version = version + 1
+  WHERE id = $1 AND deleted_at IS NOT NULL AND ($2 = 0 OR version = $2)
+  RETURNING
+   id,
+   responsible_id,
+   responsible_name,
+   context,
+   assigned_at,
+   due_date,
+   version;
+  `,
+			p.ID,
+			p.Version,
+		).Scan(
+			&t.ID,
+			&t.ResponsibleID,
+			&t.ResponsibleName,
+			&t.Context,
+			&t.AssignedAt,
+			&t.DueDate,
+			&t.Version,
+		)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.Task{}, missingOrChanged(ctx, tx, p.ID, true)
+	}
+	if err != nil {
+		return storage.Task{}, err
+	}
+	if err = recordHistory(ctx, tx, storage.ActionRestore, &before, t); err != nil {
+		return storage.Task{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return storage.Task{}, err
+	}
+	return t, nil
+}
+
+// PurgeTasks окончательно удаляет задачи, удалённые раньше deletedBefore,
+// и в той же транзакции записывает в task_history их последние значения.
+func (s *Store) PurgeTasks(ctx context.Context, deletedBefore int64) (int, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var purged []storage.Task
+	err = traced(ctx, "posts.purge", "DELETE", func(ctx context.Context) error {
+		rows, err := tx.Query(ctx, `
+  DELETE FROM posts
+  WHERE deleted_at < $1
+  RETURNING
+   id,
+   responsible_id,
+   responsible_name,
+   context,
+   assigned_at,
+   due_date,
+   version,
+   deleted_at;
+  `,
+			deletedBefore,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var p storage.Task
+			err = rows.Scan(
+				&p.ID,
+				&p.ResponsibleID,
+				&p.ResponsibleName,
+				&p.Context,
+				&p.AssignedAt,
+				&p.DueDate,
+				&p.Version,
+				&p.DeletedAt,
+			)
+			if err != nil {
+				return err
+			}
+			purged = append(purged, p)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range purged {
+		if err := insertHistory(ctx, tx, storage.NewPurgeEntry(ctx, p)); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(purged), nil
+}
+
+// TaskHistory возвращает записи task_history задачи id.
+func (s *Store) TaskHistory(ctx context.Context, id int) ([]storage.HistoryEntry, error) {
+	entries := []storage.HistoryEntry{}
+	err := traced(ctx, "task_history.select", "SELECT", func(ctx context.Context) error {
+		rows, err := s.db.Query(ctx, `
+  SELECT
+   task_id,
+   version,
+   action,
+   actor,
+   changed_at,
+   changes
+  FROM task_history
+  WHERE task_id = $1
+  ORDER BY version, id;
+ `,
+			id,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var e storage.HistoryEntry
+			err = rows.Scan(
+				&e.TaskID,
+				&e.Version,
+				&e.Action,
+				&e.Actor,
+				&e.At,
+				&e.Changes,
+			)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, e)
+		}
+		return rows.Err()
+	})
+	if err != nil || len(entries) > 0 {
+		return entries, err
+	}
+
+	// Задачи, созданные до ведения истории, истории не имеют.
+	var exists bool
+	err = traced(ctx, "posts.exists", "SELECT", func(ctx context.Context) error {
+		return s.db.QueryRow(ctx, `
+  SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1);
+ `, id).Scan(&exists)
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case !exists:
+		return nil, storage.ErrNotFound
+	}
+	return entries, nil
+}
+
+// lockTask читает задачу id, в том числе удалённую, и блокирует её строку
+// до конца транзакции tx: прежнее состояние задачи нужно для истории.
+func lockTask(ctx context.Context, tx pgx.Tx, id int) (storage.Task, error) {
+	var p storage.Task
+	err := traced(ctx, "posts.lock", "SELECT", func(ctx context.Context) error {
+		return tx.QueryRow(ctx, `
+  SELECT
+   id,
+   responsible_id,
+   responsible_name,
+   context,
+   assigned_at,
+   due_date,
+   version,
+   deleted_at
+  FROM posts
+  WHERE id = $1
+  FOR UPDATE;
+ `,
+			id,
+		).Scan(
+			&p.ID,
+			&p.ResponsibleID,
+			&p.ResponsibleName,
+			&p.Context,
+			&p.AssignedAt,
+			&p.DueDate,
+			&p.Version,
+			&p.DeletedAt,
+		)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.Task{}, storage.ErrNotFound
+	}
+	return p, err
+}
+
+// recordHistory записывает изменение задачи в task_history в той же
+// транзакции tx, в которой задача изменена.
+func recordHistory(ctx context.Context, tx pgx.Tx, action string, before *storage.Task, after storage.Task) error {
+	return insertHistory(ctx, tx, storage.NewHistoryEntry(ctx, action, before, after))
+}
+
+// insertHistory добавляет запись e в task_history в транзакции tx.
+func insertHistory(ctx context.Context, tx pgx.Tx, e storage.HistoryEntry) error {
+	return traced(ctx, "task_history.insert", "INSERT", func(ctx context.Context) error {
+		_, err := tx.Exec(ctx, `
+  INSERT INTO task_history (task_id, version, action, actor, changed_at, changes)
+  VALUES ($1, $2, $3, $4, $5, $6);
+  `,
+			e.TaskID,
+			e.Version,
+			e.Action,
+			e.Actor,
+			e.At,
+			e.Changes,
+		)
+		return err
+	})
+}
+
+// missingOrChanged объясняет, почему условное изменение задачи id не
+// затронуло строк: задачи (удалённой, если deleted, иначе действующей) нет
+// - ErrNotFound, или её версия другая - ErrVersionMismatch.
+func missingOrChanged(ctx context.Context, tx pgx.Tx, id int, deleted bool) error {
+	var exists bool
+	err := traced(ctx, "posts.exists", "SELECT", func(ctx context.Context) error {
+		return tx.QueryRow(ctx, `
+  SELECT EXISTS (
+   SELECT 1 FROM posts
+   WHERE id = $1 AND (deleted_at IS NOT NULL) = $2
+  );
+ `, id, deleted).Scan(&exists)
+	})
+	switch {
+	case err != nil:
+		return err
+	case exists:
+		return storage.ErrVersionMismatch
+	}
+	return storage.ErrNotFound
+}
+
+// Ping проверяет доступность PostgreSQL, получая соединение из пула.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.Ping(ctx)
+}
+
+// Stat возвращает статистику пула соединений.
+func (s *Store) Stat() *pgxpool.Stat {
+	return s.db.Stat()
+}
+
+// Close закрывает все соединения пула, дожидаясь возврата занятых соединений.
+func (s *Store) Close() error {
+	s.db.Close()
+	return nil
+}
+
+// mapError приводит ошибки PostgreSQL к ошибкам пакета storage.
+func mapError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch {
+	case pgErr.Code == pgerrcode.UniqueViolation:
+		return fmt.Errorf("%w: %w", storage.ErrConflict, err)
+	case pgerrcode.IsIntegrityConstraintViolation(pgErr.Code), pgerrcode.IsDataException(pgErr.Code):
+		return fmt.Errorf("%w: %w", storage.ErrInvalid, err)
+	}
+	return err
+}
+
+/*var posts = []storage.Post{
+ {
+  ID:      1,
+  Title:   "Effective Go",
+  Content: "Go is a new language. Although it borrows ideas from existing languages, it has unusual properties that make effective Go programs different in character from programs written in its relatives. A straightforward translation of a C++ or Java program into Go is unlikely to produce a satisfactory result—Java programs are written in Java, not Go. On the other hand, thinking about the problem from a Go perspective could produce a successful but quite different program. In other words, to write Go well, it's important to understand its properties and idioms. It's also important to know the established conventions for programming in Go, such as naming, formatting, program construction, and so on, so that programs you write will be easy for other Go programmers to understand.",
+ },
+ {
+  ID:      2,
+  Title:   "The Go Memory Model",
+  Content: "The Go memory model specifies the conditions under which reads of a variable in one goroutine can be guaranteed to observe values produced by writes to the same variable in a different goroutine.",
+ },
+}*/
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"strconv"
+	"strings"
+)
+
+// conditions собирает условие WHERE с позиционными параметрами.
+type conditions struct {
+	exprs []string
+	args  []any
+}
+
+// add добавляет условие, в котором каждый знак ? заменяется на очередной
+// параметр $N со значением из args.
+func (c *conditions) add(expr string, args ...any) {
+	var b strings.Builder
+	for _, r := range expr {
+		if r == '?' {
+			c.args = append(c.args, args[0])
+			args = args[1:]
+			b.WriteString("$" + strconv.Itoa(len(c.args)))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	c.exprs = append(c.exprs, b.String())
+}
+
+// addRange добавляет условия на вхождение column в диапазон [from, to].
+func (c *conditions) addRange(column string, from, to *int64) {
+	if from != nil {
+		c.add(column+" >= ?", *from)
+	}
+	if to != nil {
+		c.add(column+" <= ?", *to)
+	}
+}
+
+// sql возвращает условие WHERE или пустую строку, если условий нет.
+func (c *conditions) sql() string {
+	if len(c.exprs) == 0 {
+		return ""
+	}
+	return "\n  WHERE " + strings.Join(c.exprs, " AND ")
+}
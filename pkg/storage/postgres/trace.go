@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"context"
+	"go-news/pkg/tracing"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("go-news/pkg/storage/postgres")
+
+// traced выполняет f в span SQL-запроса. statement - имя запроса вида
+// "posts.select" (таблица и действие), operation - команда SQL.
+func traced(ctx context.Context, statement, operation string, f func(ctx context.Context) error) error {
+	table, _, _ := strings.Cut(statement, ".")
+	ctx, span := tracer.Start(ctx, "postgres "+statement,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBCollectionName(table),
+		),
+	)
+	err := f(ctx)
+	tracing.End(span, err)
+	return err
+}
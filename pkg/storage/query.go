@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Поля, по которым можно сортировать задачи.
+const (
+	SortID            = "id"
+	SortResponsibleID = "responsible_id"
+	SortAssignedAt    = "assigned_at"
+	SortDueDate       = "due_date"
+)
+
+// Ограничения на размер страницы.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// TaskQuery - параметры выборки задач. Nil-фильтр не ограничивает выборку,
+// границы диапазонов включаются в выборку.
+type TaskQuery struct {
+	ResponsibleID *int
+	DueFrom       *int64
+	DueTo         *int64
+	AssignedFrom  *int64
+	AssignedTo    *int64
+	// Deleted выбирает удалённые задачи (корзину) вместо действующих.
+	Deleted bool
+
+	// Sort - поле сортировки, при равенстве значений задачи упорядочиваются по ID.
+	Sort string
+	Desc bool
+
+	// Limit - размер страницы, Cursor - значение TaskPage.NextCursor
+	// предыдущей страницы.
+	Limit  int
+	Cursor string
+}
+
+// TaskPage - страница задач.
+type TaskPage struct {
+	Tasks []Task
+	// Total - число задач, удовлетворяющих фильтрам, без учёта пагинации.
+	Total int
+	// NextCursor пуст, если страница последняя.
+	NextCursor string
+}
+
+// Cursor - позиция последней задачи страницы в порядке сортировки.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value int64  `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// Normalize проверяет параметры запроса и подставляет значения по умолчанию.
+// Возвращает разобранный курсор или nil для первой страницы.
+func (q *TaskQuery) Normalize() (*Cursor, error) {
+	var fields []FieldError
+	if q.Sort == "" {
+		q.Sort = SortID
+	}
+	switch q.Sort {
+	case SortID, SortResponsibleID, SortAssignedAt, SortDueDate:
+	default:
+		fields = append(fields, FieldError{Field: "sort", Message: "unknown sort field " + q.Sort})
+	}
+	switch {
+	case q.Limit == 0:
+		q.Limit = DefaultLimit
+	case q.Limit < 0 || q.Limit > MaxLimit:
+		fields = append(fields, FieldError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", MaxLimit)})
+	}
+	var c *Cursor
+	if q.Cursor != "" {
+		var err error
+		if c, err = q.decodeCursor(); err != nil {
+			fields = append(fields, FieldError{Field: "cursor", Message: err.Error()})
+		}
+	}
+	if len(fields) > 0 {
+		return nil, &ValidationError{Fields: fields}
+	}
+	return c, nil
+}
+
+// Match сообщает, удовлетворяет ли задача фильтрам запроса.
+func (q TaskQuery) Match(t Task) bool {
+	return (t.DeletedAt != nil) == q.Deleted &&
+		(q.ResponsibleID == nil || t.ResponsibleID == *q.ResponsibleID) &&
+		inRange(t.DueDate, q.DueFrom, q.DueTo) &&
+		inRange(t.AssignedAt, q.AssignedFrom, q.AssignedTo)
+}
+
+// Less сообщает, идёт ли задача a раньше задачи b в порядке сортировки запроса.
+func (q TaskQuery) Less(a, b Task) bool {
+	return q.less(SortValue(a, q.Sort), a.ID, SortValue(b, q.Sort), b.ID)
+}
+
+// After сообщает, идёт ли задача после позиции курсора.
+func (q TaskQuery) After(t Task, c *Cursor) bool {
+	return q.less(c.Value, c.ID, SortValue(t, q.Sort), t.ID)
+}
+
+func (q TaskQuery) less(va int64, ida int, vb int64, idb int) bool {
+	if va == vb {
+		va, vb = int64(ida), int64(idb)
+	}
+	if q.Desc {
+		return va > vb
+	}
+	return va < vb
+}
+
+// NextCursor возвращает курсор, указывающий на задачу t.
+func (q TaskQuery) NextCursor(t Task) string {
+	b, _ := json.Marshal(Cursor{Sort: q.sortKey(), Value: SortValue(t, q.Sort), ID: t.ID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// SortValue возвращает значение поля сортировки задачи.
+func SortValue(t Task, field string) int64 {
+	switch field {
+	case SortResponsibleID:
+		return int64(t.ResponsibleID)
+	case SortAssignedAt:
+		return t.AssignedAt
+	case SortDueDate:
+		return t.DueDate
+	}
+	return int64(t.ID)
+}
+
+func (q TaskQuery) decodeCursor() (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+	if c.Sort != q.sortKey() {
+		return nil, errors.New("cursor does not match sort order")
+	}
+	return &c, nil
+}
+
+// sortKey кодирует поле и направление сортировки, чтобы курсор нельзя было
+// применить к выборке с другим порядком.
+func (q TaskQuery) sortKey() string {
+	if q.Desc {
+		return "-" + q.Sort
+	}
+	return q.Sort
+}
+
+func inRange(v int64, from, to *int64) bool {
+	return (from == nil || v >= *from) && (to == nil || v <= *to)
+}
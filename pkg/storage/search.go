@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Маркеры, которыми выделяются найденные слова во фрагменте SearchResult.Snippet.
+const (
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+)
+
+// snippetRadius - размер контекста в байтах вокруг первого совпадения во фрагменте.
+const snippetRadius = 60
+
+// SearchResult - задача, найденная поиском по тексту.
+type SearchResult struct {
+	Task Task `json:"task"`
+	// Rank - релевантность, больше - лучше. Значения сравнимы только в
+	// пределах одного ответа.
+	Rank float64 `json:"rank"`
+	// Snippet - фрагмент Context с выделенными словами запроса. Текст
+	// экранирован для HTML, кроме маркеров HighlightStart/HighlightStop.
+	Snippet string `json:"snippet"`
+}
+
+// SearchTerms разбивает поисковый запрос на слова в нижнем регистре.
+func SearchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Highlight возвращает фрагмент text вокруг первого вхождения одного из
+// слов terms, выделяя все вхождения маркерами. Сравнение без учёта регистра.
+func Highlight(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Смена регистра изменила длину в байтах, позиции в lower и text
+		// не совпадают - фрагмент берётся без выделения.
+		return html.EscapeString(truncate(text, 2*snippetRadius))
+	}
+
+	first := -1
+	for _, term := range terms {
+		if i := strings.Index(lower, term); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	start, end := 0, len(text)
+	if first >= 0 {
+		start = runeBoundary(text, first-snippetRadius)
+		end = runeBoundary(text, first+snippetRadius)
+	} else if utf8.RuneCountInString(text) > 2*snippetRadius {
+		end = runeBoundary(text, 2*snippetRadius)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		term := matchAt(lower, i, terms)
+		if term == "" {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			b.WriteString(html.EscapeString(text[i : i+size]))
+			i += size
+			continue
+		}
+		stop := min(i+len(term), len(text))
+		b.WriteString(HighlightStart + html.EscapeString(text[i:stop]) + HighlightStop)
+		i = stop
+	}
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// CountMatches возвращает число вхождений слов terms в text без учёта регистра.
+func CountMatches(text string, terms []string) int {
+	lower := strings.ToLower(text)
+	n := 0
+	for _, term := range terms {
+		n += strings.Count(lower, term)
+	}
+	return n
+}
+
+// matchAt возвращает самое длинное слово из terms, с которого начинается s[i:].
+func matchAt(s string, i int, terms []string) string {
+	var match string
+	for _, term := range terms {
+		if len(term) > len(match) && strings.HasPrefix(s[i:], term) {
+			match = term
+		}
+	}
+	return match
+}
+
+// runeBoundary ограничивает байтовую позицию i пределами s и сдвигает её
+// на начало символа UTF-8.
+func runeBoundary(s string, i int) int {
+	i = max(0, min(i, len(s)))
+	for i < len(s) && !utf8.RuneStart(s[i]) {
+		i++
+	}
+	return i
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
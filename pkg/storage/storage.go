@@ -1,18 +1,100 @@
-package storage
-
-type Task struct {
-	ID              int    `json:"id"`
-	ResponsibleID   int    `json:"responsible_id"`
-	ResponsibleName string `json:"responsible_name"`
-	Context         string `json:"context"`
-	AssignedAt      int64  `json:"assigned_at"`
-	DueDate         int64  `json:"due_date"`
-}
-
-type Interface interface {
-	Tasks() ([]Task, error)
-	AddTask(Task) error
-	UpdateTask(Task) error
-	DeleteTask(Task) error
-}
-
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// Ошибки, которые возвращают все реализации Interface. Реализации могут
+// оборачивать их, поэтому проверять следует через errors.Is.
+var (
+	// ErrNotFound - задача с указанным ID не найдена.
+	ErrNotFound = errors.New("task not found")
+	// ErrConflict - операция конфликтует с данными в хранилище (например, дубликат ключа).
+	ErrConflict = errors.New("conflict")
+	// ErrInvalid - хранилище отвергло данные задачи как некорректные.
+	ErrInvalid = errors.New("invalid task")
+	// ErrVersionMismatch - задача изменена после того, как клиент её прочитал.
+	ErrVersionMismatch = errors.New("task version mismatch")
+	// ErrHistoryNotRecorded - изменение задачи применено, но запись о нём в
+	// историю не сохранилась. Возвращается вместе с результатом операции
+	// хранилищами, которые пишут историю вне транзакции (MongoDB).
+	ErrHistoryNotRecorded = errors.New("task history not recorded")
+)
+
+type Task struct {
+	ID              int    `json:"id" bson:"id"`
+	ResponsibleID   int    `json:"responsible_id" bson:"responsible_id"`
+	ResponsibleName string `json:"responsible_name" bson:"responsible_name"`
+	Context         string `json:"context" bson:"context"`
+	AssignedAt      int64  `json:"assigned_at" bson:"assigned_at"`
+	DueDate         int64  `json:"due_date" bson:"due_date"`
+	// Version увеличивается хранилищем при каждом изменении задачи,
+	// новая задача получает версию 1.
+	Version int `json:"version" bson:"version"`
+	// DeletedAt - время удаления задачи (Unix, секунды); nil - задача не
+	// удалена. Удалённые задачи видны только в корзине и через RestoreTask.
+	DeletedAt *int64 `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+}
+
+// Interface - хранилище задач. Методы прерывают обращение к хранилищу
+// при отмене переданного контекста.
+type Interface interface {
+	Tasks(context.Context, TaskQuery) (TaskPage, error)
+	Task(ctx context.Context, id int) (Task, error)
+	// SearchTasks ищет задачи по тексту Context и возвращает не больше
+	// limit результатов в порядке убывания релевантности.
+	SearchTasks(ctx context.Context, query string, limit int) ([]SearchResult, error)
+	AddTask(context.Context, Task) (Task, error)
+	// UpdateTask заменяет задачу с ID t.ID, если её версия равна t.Version
+	// (0 - без проверки версии), и возвращает сохранённую задачу с новой
+	// версией. При несовпадении версии возвращается ErrVersionMismatch.
+	UpdateTask(ctx context.Context, t Task) (Task, error)
+	// DeleteTask помечает задачу с ID t.ID удалённой с той же проверкой
+	// версии, что UpdateTask. Удалённая задача не возвращается Task и
+	// SearchTasks и попадает в Tasks только с TaskQuery.Deleted.
+	DeleteTask(ctx context.Context, t Task) error
+	// RestoreTask возвращает удалённую задачу t.ID с проверкой версии,
+	// как в UpdateTask. Если удалённой задачи нет, возвращается ErrNotFound.
+	RestoreTask(ctx context.Context, t Task) (Task, error)
+	// PurgeTasks окончательно удаляет задачи, удалённые раньше момента
+	// deletedBefore (Unix, секунды), записывает каждую в историю с
+	// действием ActionPurge и возвращает их число.
+	PurgeTasks(ctx context.Context, deletedBefore int64) (int, error)
+	// TaskHistory возвращает историю изменений задачи id в порядке версий.
+	// AddTask, UpdateTask, DeleteTask и RestoreTask записывают в историю
+	// изменение от имени автора из контекста (см. WithActor). Если задачи
+	// нет ни в хранилище, ни в истории, возвращается ErrNotFound.
+	TaskHistory(ctx context.Context, id int) ([]HistoryEntry, error)
+	// AddAPIKey сохраняет ключ API под новым ID.
+	AddAPIKey(context.Context, APIKey) (APIKey, error)
+	// APIKeys возвращает все ключи API, в том числе отозванные, по порядку ID.
+	APIKeys(context.Context) ([]APIKey, error)
+	// APIKeyByHash возвращает ключ API с хешем hash или ErrNotFound.
+	APIKeyByHash(ctx context.Context, hash string) (APIKey, error)
+	// RevokeAPIKey отзывает ключ API id. Отзыв отозванного ключа ничего
+	// не меняет; если ключа нет, возвращается ErrNotFound.
+	RevokeAPIKey(ctx context.Context, id int) error
+	// TouchAPIKey записывает время at последнего использования ключа id.
+	TouchAPIKey(ctx context.Context, id int, at int64) error
+	// Close освобождает соединения с хранилищем.
+	Close() error
+}
+
+// Pinger - необязательная возможность хранилища проверить доступность
+// соединения. Используется проверкой готовности сервиса.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Migrator - хранилище, схема которого создаётся и обновляется
+// версионированными миграциями.
+type Migrator interface {
+	// MigrateUp применяет все ещё не применённые миграции.
+	MigrateUp(ctx context.Context) error
+	// MigrateDown откатывает n последних применённых миграций.
+	MigrateDown(ctx context.Context, n int) error
+	// SchemaVersion возвращает версию последней применённой миграции
+	// или 0, если миграции не применялись.
+	SchemaVersion(ctx context.Context) (int, error)
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Ограничения на размер текстовых полей задачи.
+const (
+	MaxContextLength         = 10000
+	MaxResponsibleNameLength = 255
+)
+
+// FieldError - ошибка в отдельном поле задачи.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError возвращается, если задача не прошла проверку.
+// errors.Is(err, ErrInvalid) для неё истинно.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+	return "invalid task: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalid
+}
+
+// Validate проверяет поля задачи перед сохранением. Поле ID не проверяется:
+// его назначает хранилище или берёт из пути запроса.
+func (t Task) Validate() error {
+	var fields []FieldError
+	add := func(field, message string) {
+		fields = append(fields, FieldError{Field: field, Message: message})
+	}
+
+	switch {
+	case strings.TrimSpace(t.Context) == "":
+		add("context", "must not be empty")
+	case utf8.RuneCountInString(t.Context) > MaxContextLength:
+		add("context", fmt.Sprintf("must be at most %d characters", MaxContextLength))
+	}
+	if utf8.RuneCountInString(t.ResponsibleName) > MaxResponsibleNameLength {
+		add("responsible_name", fmt.Sprintf("must be at most %d characters", MaxResponsibleNameLength))
+	}
+	if t.ResponsibleID < 0 {
+		add("responsible_id", "must not be negative")
+	}
+	if t.AssignedAt < 0 {
+		add("assigned_at", "must not be negative")
+	}
+	switch {
+	case t.DueDate < 0:
+		add("due_date", "must not be negative")
+	case t.DueDate < t.AssignedAt:
+		add("due_date", "must not be earlier than assigned_at")
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
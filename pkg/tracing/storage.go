@@ -0,0 +1,172 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"go-news/pkg/storage"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("go-news/pkg/tracing")
+
+// Store - хранилище, создающее span для каждого вызова метода.
+type Store struct {
+	db storage.Interface
+}
+
+// Storage оборачивает db, добавляя трассировку вызовов.
+func Storage(db storage.Interface) *Store {
+	return &Store{db: db}
+}
+
+// start начинает span вызова метода хранилища.
+func start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "storage."+method,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// End завершает span, отмечая в нём ошибку. Отсутствие задачи и
+// несовпадение версии ошибками не считаются: это обычные ответы клиенту.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if !errors.Is(err, storage.ErrNotFound) && !errors.Is(err, storage.ErrVersionMismatch) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+func (s *Store) Tasks(ctx context.Context, q storage.TaskQuery) (storage.TaskPage, error) {
+	ctx, span := start(ctx, "Tasks",
+		attribute.String("query.sort", q.Sort),
+		attribute.Int("query.limit", q.Limit),
+		attribute.Bool("query.deleted", q.Deleted),
+	)
+	page, err := s.db.Tasks(ctx, q)
+	span.SetAttributes(attribute.Int("result.count", len(page.Tasks)))
+	End(span, err)
+	return page, err
+}
+
+func (s *Store) Task(ctx context.Context, id int) (storage.Task, error) {
+	ctx, span := start(ctx, "Task", attribute.Int("task.id", id))
+	t, err := s.db.Task(ctx, id)
+	End(span, err)
+	return t, err
+}
+
+func (s *Store) SearchTasks(ctx context.Context, query string, limit int) ([]storage.SearchResult, error) {
+	ctx, span := start(ctx, "SearchTasks", attribute.Int("query.limit", limit))
+	res, err := s.db.SearchTasks(ctx, query, limit)
+	span.SetAttributes(attribute.Int("result.count", len(res)))
+	End(span, err)
+	return res, err
+}
+
+func (s *Store) AddTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	ctx, span := start(ctx, "AddTask")
+	t, err := s.db.AddTask(ctx, t)
+	span.SetAttributes(attribute.Int("task.id", t.ID))
+	End(span, err)
+	return t, err
+}
+
+func (s *Store) UpdateTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	ctx, span := start(ctx, "UpdateTask", attribute.Int("task.id", t.ID))
+	t, err := s.db.UpdateTask(ctx, t)
+	End(span, err)
+	return t, err
+}
+
+func (s *Store) DeleteTask(ctx context.Context, t storage.Task) error {
+	ctx, span := start(ctx, "DeleteTask", attribute.Int("task.id", t.ID))
+	err := s.db.DeleteTask(ctx, t)
+	End(span, err)
+	return err
+}
+
+func (s *Store) RestoreTask(ctx context.Context, t storage.Task) (storage.Task, error) {
+	ctx, span := start(ctx, "RestoreTask", attribute.Int("task.id", t.ID))
+	t, err := s.db.RestoreTask(ctx, t)
+	End(span, err)
+	return t, err
+}
+
+func (s *Store) PurgeTasks(ctx context.Context, deletedBefore int64) (int, error) {
+	ctx, span := start(ctx, "PurgeTasks", attribute.Int64("deleted_before", deletedBefore))
+	n, err := s.db.PurgeTasks(ctx, deletedBefore)
+	span.SetAttributes(attribute.Int("result.count", n))
+	End(span, err)
+	return n, err
+}
+
+func (s *Store) TaskHistory(ctx context.Context, id int) ([]storage.HistoryEntry, error) {
+	ctx, span := start(ctx, "TaskHistory", attribute.Int("task.id", id))
+	entries, err := s.db.TaskHistory(ctx, id)
+	span.SetAttributes(attribute.Int("result.count", len(entries)))
+	End(span, err)
+	return entries, err
+}
+
+func (s *Store) AddAPIKey(ctx context.Context, k storage.APIKey) (storage.APIKey, error) {
+	ctx, span := start(ctx, "AddAPIKey")
+	k, err := s.db.AddAPIKey(ctx, k)
+	span.SetAttributes(attribute.Int("api_key.id", k.ID))
+	End(span, err)
+	return k, err
+}
+
+func (s *Store) APIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	ctx, span := start(ctx, "APIKeys")
+	keys, err := s.db.APIKeys(ctx)
+	span.SetAttributes(attribute.Int("result.count", len(keys)))
+	End(span, err)
+	return keys, err
+}
+
+// APIKeyByHash не записывает хеш ключа в span.
+func (s *Store) APIKeyByHash(ctx context.Context, hash string) (storage.APIKey, error) {
+	ctx, span := start(ctx, "APIKeyByHash")
+	k, err := s.db.APIKeyByHash(ctx, hash)
+	span.SetAttributes(attribute.Int("api_key.id", k.ID))
+	End(span, err)
+	return k, err
+}
+
+func (s *Store) RevokeAPIKey(ctx context.Context, id int) error {
+	ctx, span := start(ctx, "RevokeAPIKey", attribute.Int("api_key.id", id))
+	err := s.db.RevokeAPIKey(ctx, id)
+	End(span, err)
+	return err
+}
+
+func (s *Store) TouchAPIKey(ctx context.Context, id int, at int64) error {
+	ctx, span := start(ctx, "TouchAPIKey", attribute.Int("api_key.id", id))
+	err := s.db.TouchAPIKey(ctx, id, at)
+	End(span, err)
+	return err
+}
+
+// Ping проверяет исходное хранилище, если оно реализует storage.Pinger.
+// Иначе хранилище считается доступным.
+func (s *Store) Ping(ctx context.Context) error {
+	pinger, ok := s.db.(storage.Pinger)
+	if !ok {
+		return nil
+	}
+	ctx, span := start(ctx, "Ping")
+	err := pinger.Ping(ctx)
+	End(span, err)
+	return err
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,93 @@
+// Пакет tracing настраивает трассировку OpenTelemetry.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Экспортёры трассировки.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// ServiceName - имя сервиса в трассировках.
+const ServiceName = "go-news"
+
+// Setup устанавливает глобальный провайдер трассировки с указанным
+// экспортёром и распространение контекста в формате W3C Trace Context
+// (заголовок traceparent). Для ExporterOTLP endpoint - адрес коллектора
+// (см. CheckEndpoint); пустой endpoint берётся из OTEL_EXPORTER_OTLP_ENDPOINT.
+// Возвращаемая функция отправляет накопленные span и останавливает провайдер.
+func Setup(ctx context.Context, exporter, endpoint string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exp sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if opts, err = endpointOptions(endpoint); err == nil {
+			exp, err = otlptracehttp.New(ctx, opts...)
+		}
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s exporter: %w", exporter, err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(ServiceName))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// CheckEndpoint проверяет адрес OTLP-коллектора: "https://host:port[/путь]"
+// или "host:port" - с TLS, "http://host:port[/путь]" - без шифрования, только
+// для коллектора в доверенной сети.
+func CheckEndpoint(endpoint string) error {
+	_, err := endpointOptions(endpoint)
+	return err
+}
+
+// endpointOptions возвращает настройки экспортёра OTLP для endpoint.
+func endpointOptions(endpoint string) ([]otlptracehttp.Option, error) {
+	if endpoint == "" {
+		return nil, nil
+	}
+	if !strings.Contains(endpoint, "://") {
+		return []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("otlp endpoint: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("otlp endpoint %q: want https://host:port, http://host:port or host:port", endpoint)
+	}
+	// Для http:// WithEndpointURL отключает TLS, для https:// - оставляет.
+	return []otlptracehttp.Option{otlptracehttp.WithEndpointURL(endpoint)}, nil
+}